@@ -25,10 +25,23 @@ func (a A) Match(ip net.IP) (m []string, errRtn error) {
 	return
 }
 
+// String returns the canonical form of the term. The domain is only written
+// when the record names one explicitly.
+func (a A) String() string {
+	return amxString(a.Qualifier, "a", a.Record, a.Domain, a.CIDR4, a.CIDR6)
+}
+
+func (a A) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
 func extractArecordIPs(res resolver, domain string, cidr4 string, cidr6 string) (ListOfNetworks []*net.IPNet, errRtn error) {
 	ips, err := res.ARecord(domain)
+	if isNotFound(err) {
+		return
+	}
 	if err != nil {
-		errRtn = fmt.Errorf("%w - %s", DNSResolutionError, err)
+		errRtn = fmt.Errorf("%w - %w", DNSResolutionError, err)
 		return
 	}
 	if cidr4 == "" {
@@ -76,3 +76,18 @@ func TestNewA(t *testing.T) {
 	}
 
 }
+
+func TestNewAVoidLookup(t *testing.T) {
+	res := MockAResolver{errToReturn: &net.DNSError{Err: "no such host", Name: "gone.com", IsNotFound: true}}
+	a, err := NewA("a:gone.com", "test.com", res)
+	if err != nil {
+		t.Errorf("should not have failed but got %q", err)
+	}
+	if len(a.Networks) != 0 {
+		t.Errorf("NXDOMAIN should give no networks got %v", a.Networks)
+	}
+	res.errToReturn = &net.DNSError{Err: "server misbehaving", Name: "gone.com", IsTemporary: true}
+	if _, err := NewA("a:gone.com", "test.com", res); err == nil {
+		t.Errorf("temporary failure should not be a void lookup")
+	}
+}
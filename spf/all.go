@@ -24,3 +24,16 @@ func NewAll(record string) (All, error) {
 	}
 	return All{}, fmt.Errorf("%w - %s is not all mechanism", WrongFormat, record)
 }
+
+// String returns the canonical form of the term. A pass all is written with
+// an explicit + so it stands out.
+func (a All) String() string {
+	if a.Qualifier == Pass {
+		return "+all"
+	}
+	return qualifierPrefix(a.Qualifier) + "all"
+}
+
+func (a All) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
@@ -0,0 +1,69 @@
+package spf
+
+import (
+	"math/big"
+	"net/netip"
+)
+
+// AddressSet is a set of addresses in minimal CIDR form, IPv4 first, with
+// the number of addresses of each family.
+type AddressSet struct {
+	Prefixes []netip.Prefix
+	IPv4     *big.Int
+	IPv6     *big.Int
+}
+
+// Authorized builds the record of domain and returns the addresses getting
+// each result, see SPF.Authorized.
+func Authorized(domain string, res resolver, opts ...Option) (map[Qualifier]AddressSet, error) {
+	spf, err := New(domain, res, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return spf.Authorized()
+}
+
+// Authorized returns the addresses getting each result, taking the order of
+// the mechanisms into account: an address covered by several terms counts
+// for the first one only. Every address of both families is in exactly one
+// set, so the one of the all qualifier, or neutral without all, holds the
+// rest of the internet. IPv4-mapped IPv6 addresses are not counted twice.
+// An address that fails to evaluate makes the whole result an error.
+func (spf *SPF) Authorized() (map[Qualifier]AddressSet, error) {
+	runs := make(map[Qualifier][]addrInterval)
+	sets := make(map[Qualifier]AddressSet)
+	for _, q := range []Qualifier{Pass, Fail, Softfail, Neutral} {
+		sets[q] = AddressSet{IPv4: new(big.Int), IPv6: new(big.Int)}
+	}
+	for _, r := range elementaryIntervals(treePrefixes(spf)) {
+		q, _, err := verdictAt(spf, r.From)
+		if err != nil {
+			return nil, err
+		}
+		s := sets[q]
+		count := s.IPv6
+		if r.From.Is4() {
+			count = s.IPv4
+		}
+		count.Add(count, intervalSize(r))
+
+		if n := len(runs[q]); n > 0 && runs[q][n-1].To.Next() == r.From {
+			runs[q][n-1].To = r.To
+		} else {
+			runs[q] = append(runs[q], r)
+		}
+	}
+	for q, s := range sets {
+		for _, r := range runs[q] {
+			s.Prefixes = append(s.Prefixes, r.Prefixes()...)
+		}
+		sets[q] = s
+	}
+	return sets, nil
+}
+
+func intervalSize(r addrInterval) *big.Int {
+	from := new(big.Int).SetBytes(r.From.AsSlice())
+	to := new(big.Int).SetBytes(r.To.AsSlice())
+	return to.Sub(to, from).Add(to, big.NewInt(1))
+}
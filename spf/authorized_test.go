@@ -0,0 +1,49 @@
+package spf
+
+import (
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestAuthorized(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":      []string{"v=spf1 a mx ip4:10.0.0.0/25 include:_spf.test.com ~ip4:10.0.0.0/24 ip6:2001:db8::/127 -all"},
+			"_spf.test.com": []string{"v=spf1 ip4:10.0.0.128/25 ip4:192.0.2.1 -all"},
+		},
+		mxDomains: mxDomainPair{"test.com": {{Host: "mail.test.com", Pref: 10}}},
+		aDomains: aDomainPair{
+			"test.com":      {net.ParseIP("192.0.2.1")},
+			"mail.test.com": {net.ParseIP("192.0.2.0")},
+		},
+	}
+	sets, err := Authorized("test.com", res)
+	if err != nil {
+		t.Fatalf("building the authorized set should not have failed but got %q", err)
+	}
+	pass := sets[Pass]
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("192.0.2.0/31"),
+		netip.MustParsePrefix("2001:db8::/127"),
+	}
+	if !reflect.DeepEqual(pass.Prefixes, want) {
+		t.Errorf("wrong pass prefixes wanted %v got %v", want, pass.Prefixes)
+	}
+	if pass.IPv4.Cmp(big.NewInt(258)) != 0 || pass.IPv6.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("wrong pass counts got %s and %s", pass.IPv4, pass.IPv6)
+	}
+	if len(sets[Softfail].Prefixes) != 0 || sets[Softfail].IPv4.Sign() != 0 {
+		t.Errorf("shadowed softfail should not authorize anything got %v", sets[Softfail].Prefixes)
+	}
+	all4 := new(big.Int).Lsh(big.NewInt(1), 32)
+	if got := new(big.Int).Add(sets[Fail].IPv4, pass.IPv4); got.Cmp(all4) != 0 {
+		t.Errorf("pass and fail should cover the IPv4 space got %s", got)
+	}
+	if sets[Neutral].IPv6.Sign() != 0 {
+		t.Errorf("nothing should be neutral got %s", sets[Neutral].IPv6)
+	}
+}
@@ -0,0 +1,212 @@
+package spf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"sort"
+)
+
+// BroadThreshold holds the shortest prefix lengths that are not considered
+// broad, per family.
+type BroadThreshold struct {
+	IPv4 int
+	IPv6 int
+}
+
+var DefaultBroadThreshold = BroadThreshold{IPv4: 16, IPv6: 32}
+
+// BroadAuthorization is a network shorter than the threshold that gets pass.
+// Path holds the include and redirect terms leading to Term.
+type BroadAuthorization struct {
+	Domain string
+	Path   []string
+	Term   string
+	Prefix netip.Prefix
+}
+
+// BroadAuthorizations returns every network with a shorter prefix than t
+// that gets pass, directly or through pass includes and redirects.
+func (spf *SPF) BroadAuthorizations(t BroadThreshold) (broad []BroadAuthorization) {
+	var visit func(s *SPF, path []string)
+	visit = func(s *SPF, path []string) {
+		for _, m := range s.Mechanisms {
+			switch v := m.(type) {
+			case Include:
+				if v.Qualifier == Pass {
+					visit(&v.spf, append(path[:len(path):len(path)], v.Record))
+				}
+			case A, MX, IP:
+				if qualifierOf(v) != Pass {
+					continue
+				}
+				for _, p := range networkPrefixes(v) {
+					if (p.Addr().Is4() && p.Bits() < t.IPv4) || (!p.Addr().Is4() && p.Bits() < t.IPv6) {
+						broad = append(broad, BroadAuthorization{Domain: s.Domain, Path: path, Term: termOf(v), Prefix: p})
+					}
+				}
+			}
+		}
+		if s.Redirect != nil && !s.hasAll() {
+			visit(&s.Redirect.spf, append(path[:len(path):len(path)], s.Redirect.Record))
+		}
+	}
+	visit(spf, nil)
+	return
+}
+
+// CloudRange is a published network of a cloud provider.
+type CloudRange struct {
+	Provider string
+	Service  string
+	Region   string
+	Prefix   netip.Prefix
+}
+
+// cloudFile covers the range files of AWS (ip-ranges.json), Google Cloud
+// (cloud.json) and Azure (ServiceTags_*.json).
+type cloudFile struct {
+	SyncToken string `json:"syncToken"`
+	Prefixes  []struct {
+		AWSPrefix  string `json:"ip_prefix"`
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Region     string `json:"region"`
+		Scope      string `json:"scope"`
+		Service    string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		Prefix  string `json:"ipv6_prefix"`
+		Region  string `json:"region"`
+		Service string `json:"service"`
+	} `json:"ipv6_prefixes"`
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// LoadCloudRanges reads a range file in the AWS, Google Cloud or Azure
+// format, telling them apart by their fields.
+func LoadCloudRanges(r io.Reader) (ranges []CloudRange, errRtn error) {
+	var f cloudFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to read cloud ranges: %w", err)
+	}
+	add := func(provider string, service string, region string, prefix string) {
+		p, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			if errRtn == nil {
+				errRtn = fmt.Errorf("%w - %s range %q: %w", WrongFormat, provider, prefix, err)
+			}
+			return
+		}
+		ranges = append(ranges, CloudRange{Provider: provider, Service: service, Region: region, Prefix: p.Masked()})
+	}
+	for _, v := range f.Values {
+		for _, p := range v.Properties.AddressPrefixes {
+			add("Azure", v.Name, v.Properties.Region, p)
+		}
+	}
+	for _, v := range f.Prefixes {
+		switch {
+		case v.AWSPrefix != "":
+			add("AWS", v.Service, v.Region, v.AWSPrefix)
+		case v.IPv4Prefix != "":
+			add("GCP", v.Service, v.Scope, v.IPv4Prefix)
+		case v.IPv6Prefix != "":
+			add("GCP", v.Service, v.Scope, v.IPv6Prefix)
+		}
+	}
+	for _, v := range f.IPv6Prefixes {
+		add("AWS", v.Service, v.Region, v.Prefix)
+	}
+	if errRtn != nil {
+		return nil, errRtn
+	}
+	return ranges, nil
+}
+
+func LoadCloudRangesFile(path string) ([]CloudRange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ranges, err := LoadCloudRanges(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ranges, nil
+}
+
+// CloudExposure is the part of a provider's published space that gets pass,
+// with the services whose ranges overlap it.
+type CloudExposure struct {
+	Provider string
+	Services []string
+	AddressSet
+}
+
+// CloudExposure returns, per provider, the addresses of ranges that get pass
+// for the record. Providers without any overlap are left out.
+func (spf *SPF) CloudExposure(ranges []CloudRange) (exposures []CloudExposure, errRtn error) {
+	sets, err := spf.Authorized()
+	if err != nil {
+		return nil, err
+	}
+	pass := sets[Pass].Prefixes
+	byProvider := make(map[string][]CloudRange)
+	var providers []string
+	for _, r := range ranges {
+		if _, ok := byProvider[r.Provider]; !ok {
+			providers = append(providers, r.Provider)
+		}
+		byProvider[r.Provider] = append(byProvider[r.Provider], r)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		var cloud []netip.Prefix
+		for _, r := range byProvider[provider] {
+			cloud = append(cloud, r.Prefix)
+		}
+		shared := prefixDifference(pass, prefixDifference(pass, cloud))
+		if len(shared) == 0 {
+			continue
+		}
+		e := CloudExposure{Provider: provider, AddressSet: AddressSet{Prefixes: shared, IPv4: new(big.Int), IPv6: new(big.Int)}}
+		for _, p := range shared {
+			count := e.IPv6
+			if p.Addr().Is4() {
+				count = e.IPv4
+			}
+			count.Add(count, intervalSize(addrInterval{From: p.Addr(), To: lastAddr(p)}))
+		}
+		seen := make(map[string]bool)
+		for _, r := range byProvider[provider] {
+			if seen[r.Service] || !overlaps(shared, r.Prefix) {
+				continue
+			}
+			seen[r.Service] = true
+			e.Services = append(e.Services, r.Service)
+		}
+		sort.Strings(e.Services)
+		exposures = append(exposures, e)
+	}
+	return
+}
+
+func overlaps(prefixes []netip.Prefix, p netip.Prefix) bool {
+	for _, v := range prefixes {
+		if v.Overlaps(p) {
+			return true
+		}
+	}
+	return false
+}
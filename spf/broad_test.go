@@ -0,0 +1,111 @@
+package spf
+
+import (
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	awsRanges = `{"syncToken": "1700000000", "createDate": "2024-01-01-00-00-00",
+  "prefixes": [{"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON", "network_border_group": "ap-northeast-2"},
+               {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "S3", "network_border_group": "ap-northeast-2"}],
+  "ipv6_prefixes": [{"ipv6_prefix": "2600:1f00::/24", "region": "us-east-1", "service": "EC2"}]}`
+	gcpRanges = `{"syncToken": "1700000000", "creationTime": "2024-01-01T00:00:00",
+  "prefixes": [{"ipv4Prefix": "34.80.0.0/15", "service": "Google Cloud", "scope": "asia-east1"},
+               {"ipv6Prefix": "2600:1900:4000::/44", "service": "Google Cloud", "scope": "us-central1"}]}`
+	azureRanges = `{"changeNumber": 1, "cloud": "Public",
+  "values": [{"name": "AzureCloud.westeurope", "id": "AzureCloud.westeurope",
+              "properties": {"changeNumber": 1, "region": "westeurope", "regionId": 18, "platform": "Azure",
+                             "systemService": "", "addressPrefixes": ["13.69.0.0/17", "2603:1020::/47"]}}]}`
+)
+
+func TestLoadCloudRanges(t *testing.T) {
+	TestTable := []struct {
+		testCase string
+		file     string
+		want     []CloudRange
+	}{
+		{"aws", awsRanges, []CloudRange{
+			{"AWS", "AMAZON", "ap-northeast-2", netip.MustParsePrefix("3.5.140.0/22")},
+			{"AWS", "S3", "ap-northeast-2", netip.MustParsePrefix("3.5.140.0/22")},
+			{"AWS", "EC2", "us-east-1", netip.MustParsePrefix("2600:1f00::/24")},
+		}},
+		{"gcp", gcpRanges, []CloudRange{
+			{"GCP", "Google Cloud", "asia-east1", netip.MustParsePrefix("34.80.0.0/15")},
+			{"GCP", "Google Cloud", "us-central1", netip.MustParsePrefix("2600:1900:4000::/44")},
+		}},
+		{"azure", azureRanges, []CloudRange{
+			{"Azure", "AzureCloud.westeurope", "westeurope", netip.MustParsePrefix("13.69.0.0/17")},
+			{"Azure", "AzureCloud.westeurope", "westeurope", netip.MustParsePrefix("2603:1020::/47")},
+		}},
+	}
+	for _, test := range TestTable {
+		got, err := LoadCloudRanges(strings.NewReader(test.file))
+		if err != nil {
+			t.Fatalf("%s: loading should not have failed but got %q", test.testCase, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: wrong ranges wanted\n%v\ngot\n%v", test.testCase, test.want, got)
+		}
+	}
+	if _, err := LoadCloudRanges(strings.NewReader(`{"prefixes": [{"ip_prefix": "3.5.140.0/33"}]}`)); err == nil {
+		t.Errorf("malformed prefix should have failed")
+	}
+}
+
+func TestBroadAuthorizations(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":       []string{"v=spf1 ip4:10.0.0.0/8 -ip4:11.0.0.0/8 include:cloud.test.com ~include:soft.test.com -all"},
+			"cloud.test.com": []string{"v=spf1 ip4:34.80.0.0/16 ip4:3.5.141.0/24 ip6:2600:1f00::/32 ip4:13.69.0.0/14 -all"},
+			"soft.test.com":  []string{"v=spf1 ip4:12.0.0.0/8 -all"},
+		},
+	}
+	spf, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	want := []BroadAuthorization{
+		{"test.com", nil, "ip4:10.0.0.0/8", netip.MustParsePrefix("10.0.0.0/8")},
+		{"cloud.test.com", []string{"include:cloud.test.com"}, "ip4:13.69.0.0/14", netip.MustParsePrefix("13.68.0.0/14")},
+	}
+	if got := spf.BroadAuthorizations(DefaultBroadThreshold); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong broad authorizations wanted\n%v\ngot\n%v", want, got)
+	}
+
+	var ranges []CloudRange
+	for _, f := range []string{awsRanges, gcpRanges, azureRanges} {
+		r, err := LoadCloudRanges(strings.NewReader(f))
+		if err != nil {
+			t.Fatalf("loading should not have failed but got %q", err)
+		}
+		ranges = append(ranges, r...)
+	}
+	exposures, err := spf.CloudExposure(ranges)
+	if err != nil {
+		t.Fatalf("exposure should not have failed but got %q", err)
+	}
+	TestTable := []struct {
+		provider string
+		services []string
+		ipv4     int64
+		ipv6     *big.Int
+	}{
+		{"AWS", []string{"AMAZON", "EC2", "S3"}, 256, new(big.Int).Lsh(big.NewInt(1), 96)},
+		{"Azure", []string{"AzureCloud.westeurope"}, 1 << 15, big.NewInt(0)},
+		{"GCP", []string{"Google Cloud"}, 1 << 16, big.NewInt(0)},
+	}
+	if len(exposures) != len(TestTable) {
+		t.Fatalf("wanted %d exposures got %+v", len(TestTable), exposures)
+	}
+	for i, test := range TestTable {
+		e := exposures[i]
+		if e.Provider != test.provider || !reflect.DeepEqual(e.Services, test.services) ||
+			e.IPv4.Cmp(big.NewInt(test.ipv4)) != 0 || e.IPv6.Cmp(test.ipv6) != 0 {
+			t.Errorf("wrong exposure for %s got %s %v %s %s", test.provider, e.Provider, e.Services, e.IPv4, e.IPv6)
+		}
+	}
+}
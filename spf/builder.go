@@ -0,0 +1,132 @@
+package spf
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Builder composes a record term by term and renders it in canonical form.
+// Mechanisms are written in the order they were added, followed by the
+// redirect and exp modifiers.
+//
+//	record := NewBuilder().MX(Pass, "").Include(Pass, "_spf.example.com").All(Fail).String()
+type Builder struct {
+	terms    []string
+	redirect string
+	exp      string
+	err      error
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Add appends any mechanism, a Redirect sets the redirect modifier.
+func (b *Builder) Add(m Mechanism) *Builder {
+	switch v := m.(type) {
+	case Redirect:
+		b.redirect = v.String()
+	case fmt.Stringer:
+		b.terms = append(b.terms, v.String())
+	default:
+		if b.err == nil {
+			b.err = fmt.Errorf("%w - %T can not be written to a record", WrongMechanism, m)
+		}
+	}
+	return b
+}
+
+// A appends an a mechanism, an empty domain means the domain of the record.
+func (b *Builder) A(q Qualifier, domain string) *Builder {
+	return b.Add(A{Qualifier: q, Domain: domain})
+}
+
+// MX appends an mx mechanism, an empty domain means the domain of the record.
+func (b *Builder) MX(q Qualifier, domain string) *Builder {
+	return b.Add(MX{Qualifier: q, Domain: domain})
+}
+
+// IP appends an ip4 or ip6 mechanism for p.
+func (b *Builder) IP(q Qualifier, p netip.Prefix) *Builder {
+	if !p.IsValid() {
+		if b.err == nil {
+			b.err = fmt.Errorf("%w - invalid prefix %s", WrongFormat, p)
+		}
+		return b
+	}
+	b.terms = append(b.terms, prefixTerm(q, p.Masked()))
+	return b
+}
+
+func (b *Builder) Include(q Qualifier, domain string) *Builder {
+	return b.Add(Include{Qualifier: q, Domain: domain})
+}
+
+func (b *Builder) Exists(q Qualifier, domain string) *Builder {
+	b.terms = append(b.terms, qualifierPrefix(q)+"exists:"+domainSpec(domain))
+	return b
+}
+
+func (b *Builder) All(q Qualifier) *Builder {
+	return b.Add(All{Qualifier: q})
+}
+
+func (b *Builder) Redirect(domain string) *Builder {
+	return b.Add(Redirect{Domain: domain})
+}
+
+func (b *Builder) Exp(domain string) *Builder {
+	b.exp = "exp=" + domainSpec(domain)
+	return b
+}
+
+func (b *Builder) String() string {
+	terms := append([]string{"v=spf1"}, b.terms...)
+	if b.redirect != "" {
+		terms = append(terms, b.redirect)
+	}
+	if b.exp != "" {
+		terms = append(terms, b.exp)
+	}
+	return strings.Join(terms, " ")
+}
+
+// MarshalText returns the record, or the first error met while building it.
+func (b *Builder) MarshalText() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return []byte(b.String()), nil
+}
+
+// domainSpec returns domain in canonical form. Macros are case sensitive
+// and left alone.
+func domainSpec(domain string) string {
+	if strings.Contains(domain, "%") {
+		return domain
+	}
+	return canonicalName(domain)
+}
+
+// amxString renders an a or mx term. When the term was parsed from a record
+// the domain is taken from there, because the parsed Domain is filled with
+// the record's own domain when none was given.
+func amxString(q Qualifier, name string, record string, domain string, cidr4 string, cidr6 string) string {
+	if record != "" {
+		if _, _, d, _, _, err := matchAMX(record); err == nil {
+			domain = d
+		}
+	}
+	s := qualifierPrefix(q) + name
+	if domain != "" {
+		s += ":" + domainSpec(domain)
+	}
+	if cidr4 != "" && cidr4 != "32" {
+		s += "/" + cidr4
+	}
+	if cidr6 != "" && cidr6 != "128" {
+		s += "//" + cidr6
+	}
+	return s
+}
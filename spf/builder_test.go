@@ -0,0 +1,75 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestMechanismString(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{"_spf.test.com": []string{"v=spf1 -all"}},
+		aDomains:   aDomainPair{"test.com": {net.ParseIP("10.0.0.1")}},
+	}
+	a, _ := NewA("a", "test.com", res)
+	aCIDR, _ := NewA("-a:Mail.Test.com/24", "test.com", res)
+	mx, _ := NewMX("~mx/30", "test.com", res)
+	ip4, _ := NewIP("ip4:10.0.0.5/24")
+	ip6, _ := NewIP("?ip6:2001:db8::1")
+	include, _ := NewInclude("include:_spf.test.com", res)
+	all, _ := NewAll("-all")
+	redirect, _ := NewRedirect("redirect=_spf.test.com", res)
+	TestTable := []struct {
+		testCase string
+		m        Mechanism
+		want     string
+	}{
+		{"a", a, "a"},
+		{"a with domain and prefix", aCIDR, "-a:mail.test.com/24"},
+		{"mx with prefix", mx, "~mx/30"},
+		{"ip4 network", ip4, "ip4:10.0.0.0/24"},
+		{"ip6 address", ip6, "?ip6:2001:db8::1"},
+		{"include", include, "include:_spf.test.com"},
+		{"include from fields", Include{Qualifier: Softfail, Domain: "_SPF.Test.com."}, "~include:_spf.test.com"},
+		{"all", all, "-all"},
+		{"pass all", All{Qualifier: Pass}, "+all"},
+		{"redirect", redirect, "redirect=_spf.test.com"},
+		{"a from fields", A{Qualifier: Fail, Domain: "x.test.com", CIDR6: "64"}, "-a:x.test.com//64"},
+	}
+	for _, test := range TestTable {
+		text, err := test.m.(interface{ MarshalText() ([]byte, error) }).MarshalText()
+		if err != nil || string(text) != test.want {
+			t.Errorf("%s: wanted %q got %q (%v)", test.testCase, test.want, text, err)
+		}
+	}
+	if text, _ := Softfail.MarshalText(); string(text) != "softfail" {
+		t.Errorf("wrong qualifier text got %q", text)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder().
+		MX(Pass, "").
+		A(Pass, "Mail.Test.com").
+		IP(Pass, netip.MustParsePrefix("192.0.2.7/24")).
+		IP(Fail, netip.MustParsePrefix("2001:db8::1/128")).
+		Include(Softfail, "_spf.test.com").
+		Exists(Neutral, "%{i}.rbl.test.com").
+		Exp("explain.test.com").
+		Redirect("other.test.com")
+	want := "v=spf1 mx a:mail.test.com ip4:192.0.2.0/24 -ip6:2001:db8::1 ~include:_spf.test.com " +
+		"?exists:%{i}.rbl.test.com redirect=other.test.com exp=explain.test.com"
+	text, err := b.MarshalText()
+	if err != nil {
+		t.Fatalf("building should not have failed but got %q", err)
+	}
+	if string(text) != want {
+		t.Errorf("wrong record wanted\n%q\ngot\n%q", want, text)
+	}
+
+	_, err = NewBuilder().IP(Pass, netip.Prefix{}).All(Fail).MarshalText()
+	if !errors.Is(err, WrongFormat) {
+		t.Errorf("invalid prefix should have failed with %q got %v", WrongFormat, err)
+	}
+}
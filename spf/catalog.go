@@ -0,0 +1,193 @@
+package spf
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// Provider is a known sender of mail on behalf of other domains. Includes
+// are the domains customers include, a domain below one of them belongs to
+// the provider as well. Networks are ranges known to belong to it.
+type Provider struct {
+	Name     string
+	Category string
+	DocsURL  string
+	Includes []string
+	Networks []netip.Prefix
+}
+
+// Catalog maps include domains and addresses to providers. It is safe for
+// concurrent use.
+type Catalog struct {
+	mu        sync.RWMutex
+	providers []*Provider
+}
+
+func NewCatalog(providers ...Provider) *Catalog {
+	c := &Catalog{}
+	for _, p := range providers {
+		c.Add(p)
+	}
+	return c
+}
+
+// Add adds p, an earlier provider wins when both claim a domain or address.
+func (c *Catalog) Add(p Provider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.providers = append(c.providers, &p)
+}
+
+// Providers returns every provider of the catalog in the order they were
+// added.
+func (c *Catalog) Providers() []Provider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	providers := make([]Provider, 0, len(c.providers))
+	for _, p := range c.providers {
+		providers = append(providers, *p.clone())
+	}
+	return providers
+}
+
+// clone returns a copy of p that shares no slice with it, so callers
+// cannot change the catalog.
+func (p *Provider) clone() *Provider {
+	cp := *p
+	cp.Includes = append([]string(nil), p.Includes...)
+	cp.Networks = append([]netip.Prefix(nil), p.Networks...)
+	return &cp
+}
+
+// ByInclude returns a copy of the provider of an include domain.
+func (c *Catalog) ByInclude(domain string) (*Provider, bool) {
+	domain = canonicalName(domain)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, p := range c.providers {
+		for _, v := range p.Includes {
+			v = canonicalName(v)
+			if domain == v || strings.HasSuffix(domain, "."+v) {
+				return p.clone(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ByAddress returns a copy of the provider whose networks contain addr.
+// DefaultCatalog lists no networks, so it only matches in catalogs built
+// with them.
+func (c *Catalog) ByAddress(addr netip.Addr) (*Provider, bool) {
+	addr = addr.Unmap()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, p := range c.providers {
+		for _, n := range p.Networks {
+			if n.Contains(addr) {
+				return p.clone(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// WithCatalog annotates includes with the providers of c instead of
+// DefaultCatalog.
+func WithCatalog(c *Catalog) Option {
+	return func(spf *SPF) {
+		spf.catalog = c
+	}
+}
+
+func (spf *SPF) providers() *Catalog {
+	if spf.catalog != nil {
+		return spf.catalog
+	}
+	return DefaultCatalog
+}
+
+// TracedTerm is a term of a verdict chain. Provider is the known provider
+// of the domain an include or redirect term names, nil for other terms and
+// unknown domains.
+type TracedTerm struct {
+	Term     string
+	Provider *Provider
+}
+
+// Trace evaluates ip like Verdict and returns the chain with every include
+// and redirect annotated with its provider.
+func (spf *SPF) Trace(ip net.IP) (Qualifier, []TracedTerm, error) {
+	q, chain, err := spf.Verdict(ip)
+	if err != nil {
+		return q, nil, err
+	}
+	traced := make([]TracedTerm, 0, len(chain))
+	cur := spf
+	for _, term := range chain {
+		t := TracedTerm{Term: term}
+		if cur.Redirect != nil && cur.Redirect.Record == term {
+			t.Provider, _ = cur.providers().ByInclude(cur.Redirect.Domain)
+			cur = &cur.Redirect.spf
+			traced = append(traced, t)
+			continue
+		}
+		for _, m := range cur.Mechanisms {
+			if i, ok := m.(Include); ok && i.Record == term {
+				t.Provider = i.Provider
+				cur = &i.spf
+				break
+			}
+		}
+		traced = append(traced, t)
+	}
+	return q, traced, nil
+}
+
+// Provider categories of DefaultCatalog.
+const (
+	CategoryMailbox       = "mailbox hosting"
+	CategoryTransactional = "transactional email"
+	CategoryMarketing     = "marketing email"
+	CategorySecurity      = "email security"
+	CategorySupport       = "customer support"
+	CategoryCRM           = "crm"
+)
+
+// DefaultCatalog holds widely used mail providers by their include
+// domains. It holds no networks: providers change their ranges without
+// notice and publish them through the records of these includes.
+var DefaultCatalog = NewCatalog(
+	Provider{Name: "Google Workspace", Category: CategoryMailbox, Includes: []string{"_spf.google.com"},
+		DocsURL: "https://support.google.com/a/answer/10685031"},
+	Provider{Name: "Microsoft 365", Category: CategoryMailbox, Includes: []string{"spf.protection.outlook.com"},
+		DocsURL: "https://learn.microsoft.com/en-us/defender-office-365/email-authentication-spf-configure"},
+	Provider{Name: "Zoho Mail", Category: CategoryMailbox, Includes: []string{"zoho.com", "zoho.eu", "zohomail.com"},
+		DocsURL: "https://www.zoho.com/mail/help/"},
+	Provider{Name: "Fastmail", Category: CategoryMailbox, Includes: []string{"spf.messagingengine.com"},
+		DocsURL: "https://www.fastmail.help/"},
+	Provider{Name: "SendGrid", Category: CategoryTransactional, Includes: []string{"sendgrid.net"},
+		DocsURL: "https://www.twilio.com/docs/sendgrid"},
+	Provider{Name: "Mailgun", Category: CategoryTransactional, Includes: []string{"mailgun.org"},
+		DocsURL: "https://documentation.mailgun.com/"},
+	Provider{Name: "Amazon SES", Category: CategoryTransactional, Includes: []string{"amazonses.com"},
+		DocsURL: "https://docs.aws.amazon.com/ses/latest/dg/send-email-authentication-spf.html"},
+	Provider{Name: "Postmark", Category: CategoryTransactional, Includes: []string{"spf.mtasv.net"},
+		DocsURL: "https://postmarkapp.com/support"},
+	Provider{Name: "SparkPost", Category: CategoryTransactional, Includes: []string{"sparkpostmail.com"},
+		DocsURL: "https://support.sparkpost.com/"},
+	Provider{Name: "Mailchimp", Category: CategoryMarketing, Includes: []string{"servers.mcsv.net", "spf.mandrillapp.com"},
+		DocsURL: "https://mailchimp.com/help/"},
+	Provider{Name: "HubSpot", Category: CategoryMarketing, Includes: []string{"hubspotemail.net"},
+		DocsURL: "https://knowledge.hubspot.com/"},
+	Provider{Name: "Mimecast", Category: CategorySecurity, Includes: []string{"_netblocks.mimecast.com"},
+		DocsURL: "https://community.mimecast.com/"},
+	Provider{Name: "Zendesk", Category: CategorySupport, Includes: []string{"mail.zendesk.com"},
+		DocsURL: "https://support.zendesk.com/"},
+	Provider{Name: "Freshdesk", Category: CategorySupport, Includes: []string{"email.freshdesk.com"},
+		DocsURL: "https://support.freshdesk.com/"},
+	Provider{Name: "Salesforce", Category: CategoryCRM, Includes: []string{"_spf.salesforce.com"},
+		DocsURL: "https://help.salesforce.com/"},
+)
@@ -0,0 +1,141 @@
+package spf
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	c := NewCatalog(
+		Provider{Name: "Example Mail", Category: CategoryTransactional, Includes: []string{"spf.example.net"},
+			Networks: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}},
+		Provider{Name: "Shadowed", Includes: []string{"example.net"}},
+	)
+	TestTable := []struct {
+		testCase string
+		domain   string
+		want     string
+	}{
+		{"exact", "spf.example.net", "Example Mail"},
+		{"case and trailing dot", "SPF.Example.net.", "Example Mail"},
+		{"customer subdomain", "u123.spf.example.net", "Example Mail"},
+		{"parent claimed by a later provider", "other.example.net", "Shadowed"},
+		{"unknown", "example.org", ""},
+	}
+	for _, test := range TestTable {
+		p, ok := c.ByInclude(test.domain)
+		if ok != (test.want != "") || (ok && p.Name != test.want) {
+			t.Errorf("%s: wanted %q got %v", test.testCase, test.want, p)
+		}
+	}
+	if p, ok := c.ByAddress(netip.MustParseAddr("::ffff:192.0.2.9")); !ok || p.Name != "Example Mail" {
+		t.Errorf("mapped address should belong to Example Mail got %v", p)
+	}
+	if _, ok := c.ByAddress(netip.MustParseAddr("198.51.100.1")); ok {
+		t.Errorf("address should not belong to any provider")
+	}
+
+	p, _ := c.ByInclude("spf.example.net")
+	p.Name = "Changed"
+	p.Includes[0] = "changed.example.net"
+	if p, ok := c.ByInclude("spf.example.net"); !ok || p.Name != "Example Mail" {
+		t.Errorf("changing a returned provider should not change the catalog got %v", p)
+	}
+}
+
+func TestIncludeProvider(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":        []string{"v=spf1 include:_spf.google.com include:sendgrid.net include:sendgrid.net include:spf.example.net -all"},
+			"_spf.google.com": []string{"v=spf1 ip4:172.217.0.0/19 ~all"},
+			"sendgrid.net":    []string{"v=spf1 ip4:167.89.0.0/17 -all"},
+			"spf.example.net": []string{"v=spf1 ip4:192.0.2.0/24 -all"},
+			"redir.test.com":  []string{"v=spf1 redirect=sendgrid.net"},
+		},
+	}
+	spf, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	names := func(spf SPF) (names []string) {
+		for _, m := range spf.Mechanisms {
+			if i, ok := m.(Include); ok {
+				name := ""
+				if i.Provider != nil {
+					name = i.Provider.Name
+				}
+				names = append(names, name)
+			}
+		}
+		return
+	}
+	if got := names(spf); got[0] != "Google Workspace" || got[1] != "SendGrid" || got[3] != "" {
+		t.Errorf("wrong default providers got %q", got)
+	}
+	if c := spf.LookupCost(); c[0].Provider == nil || c[0].Provider.Name != "Google Workspace" {
+		t.Errorf("lookup cost should carry the provider got %+v", c[0].Provider)
+	}
+
+	TraceTable := []struct {
+		domain string
+		ip     string
+		want   []TracedTerm
+	}{
+		{"test.com", "172.217.0.1", []TracedTerm{
+			{Term: "include:_spf.google.com", Provider: &Provider{Name: "Google Workspace"}},
+			{Term: "ip4:172.217.0.0/19"},
+		}},
+		{"test.com", "192.0.2.1", []TracedTerm{
+			{Term: "include:spf.example.net"},
+			{Term: "ip4:192.0.2.0/24"},
+		}},
+		{"redir.test.com", "167.89.0.1", []TracedTerm{
+			{Term: "redirect=sendgrid.net", Provider: &Provider{Name: "SendGrid"}},
+			{Term: "ip4:167.89.0.0/17"},
+		}},
+	}
+	for _, test := range TraceTable {
+		spf, err := New(test.domain, res)
+		if err != nil {
+			t.Fatalf("creating SPF should not have failed but got %q", err)
+		}
+		q, traced, err := spf.Trace(net.ParseIP(test.ip))
+		if err != nil || q != Pass || len(traced) != len(test.want) {
+			t.Fatalf("trace of %s should pass got %v %+v %v", test.ip, q, traced, err)
+		}
+		for i, want := range test.want {
+			got := traced[i]
+			if got.Term != want.Term || (got.Provider == nil) != (want.Provider == nil) ||
+				(got.Provider != nil && got.Provider.Name != want.Provider.Name) {
+				t.Errorf("wrong trace term %d for %s wanted %+v got %+v", i, test.ip, want, got)
+			}
+		}
+	}
+
+	custom := NewCatalog(Provider{Name: "Example Mail", Includes: []string{"spf.example.net"}})
+	spf, err = New("test.com", res, WithCatalog(custom))
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	if got := names(spf); got[0] != "" || got[3] != "Example Mail" {
+		t.Errorf("wrong custom providers got %q", got)
+	}
+
+	findings, err := Lint("test.com", res)
+	if err != nil {
+		t.Fatalf("lint should not have failed but got %q", err)
+	}
+	found := false
+	for _, f := range findings {
+		if f.Code == LintDuplicateInclude {
+			found = true
+			if f.Provider == nil || f.Provider.Name != "SendGrid" {
+				t.Errorf("duplicate include finding should name SendGrid got %+v", f.Provider)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("lint should have found the duplicate include got %+v", findings)
+	}
+}
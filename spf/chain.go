@@ -0,0 +1,97 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ChainNotPossible = errors.New("record does not fit in a chain of TXT records")
+
+// ChainRecord is one TXT record of a chain. Strings are the character
+// strings to publish, in order; Size is the estimated size of the DNS
+// response carrying the record.
+type ChainRecord struct {
+	Name    string
+	Record  string
+	Strings []string
+	Size    int
+}
+
+// Chain is a set of records that together are equivalent to a flattened
+// record, the record of the domain itself first.
+type Chain struct {
+	Records []ChainRecord
+	Lookups int
+}
+
+// PlanChain splits the flattened record f of domain into records that each
+// fit in TXT strings of 255 octets and a UDP response of 512 bytes. Runs of
+// pass ip4 and ip6 terms are moved into records named _spf1, _spf2, ...
+// below domain and replaced by includes of them; every other term stays in
+// the record of domain, in order, because a non-pass term behaves
+// differently inside an include. Each moved run is packed into as few
+// records as possible so the chain costs the fewest extra lookups.
+func PlanChain(domain string, f Flattened) (Chain, error) {
+	domain = canonicalName(domain)
+	if r := chainRecord(domain, f.Record); r.Size <= maxUDPResponse {
+		return Chain{Records: []ChainRecord{r}, Lookups: f.Lookups}, nil
+	}
+	var chain Chain
+	apex := []string{"v=spf1"}
+	var run []string
+	flush := func() error {
+		for len(run) > 0 {
+			name := fmt.Sprintf("_spf%d.%s", len(chain.Records)+1, domain)
+			n := 1
+			for n < len(run) && chainRecord(name, includedRecord(run[:n+1])).Size <= maxUDPResponse {
+				n++
+			}
+			r := chainRecord(name, includedRecord(run[:n]))
+			if r.Size > maxUDPResponse {
+				return fmt.Errorf("%w - %s does not fit in %s", ChainNotPossible, run[0], name)
+			}
+			chain.Records = append(chain.Records, r)
+			apex = append(apex, "include:"+name)
+			run = run[n:]
+		}
+		return nil
+	}
+	for _, term := range strings.Fields(f.Record)[1:] {
+		if name := termName(term); (name == "ip4" || name == "ip6") && term[0] != '-' && term[0] != '~' && term[0] != '?' {
+			run = append(run, term)
+			continue
+		}
+		if err := flush(); err != nil {
+			return Chain{}, err
+		}
+		apex = append(apex, term)
+	}
+	if err := flush(); err != nil {
+		return Chain{}, err
+	}
+	r := chainRecord(domain, strings.Join(apex, " "))
+	if r.Size > maxUDPResponse {
+		return Chain{}, fmt.Errorf("%w - the record of %s is still %d bytes", ChainNotPossible, domain, r.Size)
+	}
+	chain.Records = append([]ChainRecord{r}, chain.Records...)
+	chain.Lookups = f.Lookups + len(chain.Records) - 1
+	if chain.Lookups > MaxLookups {
+		return Chain{}, fmt.Errorf("%w - the chain needs %d lookups", ChainNotPossible, chain.Lookups)
+	}
+	return chain, nil
+}
+
+func includedRecord(terms []string) string {
+	return "v=spf1 " + strings.Join(terms, " ") + " -all"
+}
+
+func chainRecord(name string, record string) ChainRecord {
+	strs := splitTXT(record)
+	return ChainRecord{
+		Name:    name,
+		Record:  record,
+		Strings: strs,
+		Size:    txtResponseSize(name, [][]string{strs}),
+	}
+}
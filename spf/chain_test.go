@@ -0,0 +1,93 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func chainTestRecord(networks int) string {
+	terms := []string{"v=spf1"}
+	for i := 0; i < networks; i++ {
+		terms = append(terms, fmt.Sprintf("ip4:10.%d.%d.0/24", i/100, i%100*2))
+	}
+	return strings.Join(append(terms, "include:mixed.test.com", "~ip4:203.0.113.0/24", "-all"), " ")
+}
+
+func TestPlanChain(t *testing.T) {
+	f := Flattened{Record: chainTestRecord(120), Lookups: 1}
+	chain, err := PlanChain("Test.com.", f)
+	if err != nil {
+		t.Fatalf("planning should not have failed but got %q", err)
+	}
+	if len(chain.Records) < 3 {
+		t.Fatalf("wanted a chain of at least 3 records got %d", len(chain.Records))
+	}
+	if chain.Lookups != f.Lookups+len(chain.Records)-1 {
+		t.Errorf("wrong lookups got %d", chain.Lookups)
+	}
+	apex := chain.Records[0]
+	if apex.Name != "test.com" || !strings.HasSuffix(apex.Record, "include:mixed.test.com ~ip4:203.0.113.0/24 -all") {
+		t.Errorf("wrong apex record %s: %q", apex.Name, apex.Record)
+	}
+	res := MockResolver{txtDomains: txtDomainPair{
+		"original.com":   []string{f.Record},
+		"mixed.test.com": []string{"v=spf1 -ip4:10.9.9.9 ip4:198.51.100.0/24 -all"},
+	}}
+	for i, r := range chain.Records {
+		if i > 0 && r.Name != fmt.Sprintf("_spf%d.test.com", i) {
+			t.Errorf("wrong name of record %d got %q", i, r.Name)
+		}
+		if r.Size > maxUDPResponse {
+			t.Errorf("%s: response of %d bytes is too large", r.Name, r.Size)
+		}
+		for _, s := range r.Strings {
+			if len(s) > maxTXTString {
+				t.Errorf("%s: string of %d characters is too long", r.Name, len(s))
+			}
+		}
+		if strings.Join(r.Strings, "") != r.Record {
+			t.Errorf("%s: strings do not join to the record", r.Name)
+		}
+		res.txtDomains[r.Name] = []string{r.Record}
+	}
+
+	original, err := New("original.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	chained, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating chained SPF should not have failed but got %q", err)
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.1.1", "10.1.38.9", "10.0.198.1", "10.9.9.9", "198.51.100.1", "203.0.113.9", "8.8.8.8"} {
+		wantQ, _, _ := original.Verdict(net.ParseIP(ip))
+		gotQ, _, _ := chained.Verdict(net.ParseIP(ip))
+		if wantQ != gotQ {
+			t.Errorf("%s: chained verdict %s differs from %s", ip, gotQ, wantQ)
+		}
+	}
+}
+
+func TestPlanChainLimits(t *testing.T) {
+	TestTable := []struct {
+		testCase string
+		f        Flattened
+		records  int
+		err      error
+	}{
+		{"fits in a single record", Flattened{Record: chainTestRecord(5), Lookups: 1}, 1, nil},
+		{"too many lookups", Flattened{Record: chainTestRecord(500), Lookups: 1}, 0, ChainNotPossible},
+	}
+	for _, test := range TestTable {
+		chain, err := PlanChain("test.com", test.f)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: wanted error %v got %v", test.testCase, test.err, err)
+		}
+		if len(chain.Records) != test.records {
+			t.Errorf("%s: wanted %d records got %d", test.testCase, test.records, len(chain.Records))
+		}
+	}
+}
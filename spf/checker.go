@@ -0,0 +1,240 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheTTL = 5 * time.Minute
+	// DefaultNegativeCacheTTL is how long a domain without a record, or with
+	// a record that fails with a permerror, is remembered.
+	DefaultNegativeCacheTTL = time.Minute
+	DefaultMaxCacheEntries  = 10000
+)
+
+type CheckerOption func(*Checker)
+
+// WithCacheTTL sets how long parsed records are kept when the resolver does
+// not report TTLs.
+func WithCacheTTL(ttl time.Duration) CheckerOption {
+	return func(c *Checker) {
+		c.ttl = ttl
+	}
+}
+
+// WithNegativeCacheTTL sets how long NoSPFRecordPublished and permerror
+// results are kept, at most as long as the answers they came from.
+func WithNegativeCacheTTL(ttl time.Duration) CheckerOption {
+	return func(c *Checker) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithMaxCacheEntries bounds the number of cached domains, entries closest
+// to expiry are dropped first. Zero or less removes the bound, the default
+// is DefaultMaxCacheEntries.
+func WithMaxCacheEntries(n int) CheckerOption {
+	return func(c *Checker) {
+		c.maxEntries = n
+	}
+}
+
+// WithQueryLimits routes every query through a LimitedResolver with the
+// given rate and concurrency limits, and lets building the record of a
+// domain make at most budget queries. Zero or less disables a limit.
+func WithQueryLimits(qps float64, maxInFlight int, budget int) CheckerOption {
+	return func(c *Checker) {
+		c.r = NewLimitedResolver(c.r, qps, maxInFlight, 0)
+		c.budget = budget
+	}
+}
+
+// WithSPFOptions passes opts to New for every record the checker builds.
+func WithSPFOptions(opts ...Option) CheckerOption {
+	return func(c *Checker) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+type checkerEntry struct {
+	spf     *SPF
+	err     error
+	expires time.Time
+}
+
+// Checker evaluates IPs against the SPF records of many domains and is safe
+// for concurrent use. Parsed records are reused until the shortest TTL of the
+// answers they were built from expires, TXT as well as the A, AAAA and MX
+// answers of a and mx terms; concurrent checks of a domain that is not cached
+// share a single evaluation. Domains without a record and records that fail
+// with a permerror are cached too, for a shorter time.
+type Checker struct {
+	r           resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	budget      int
+	opts        []Option
+	now         func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]checkerEntry
+	g     flightGroup
+}
+
+func NewChecker(res resolver, opts ...CheckerOption) *Checker {
+	c := &Checker{
+		r:           res,
+		ttl:         DefaultCacheTTL,
+		negativeTTL: DefaultNegativeCacheTTL,
+		maxEntries:  DefaultMaxCacheEntries,
+		now:         time.Now,
+		cache:       make(map[string]checkerEntry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.r = NewCoalescingResolver(c.r)
+	return c
+}
+
+// Check returns the verdict for ip on domain, see SPF.Verdict.
+func (c *Checker) Check(domain string, ip net.IP) (Qualifier, []string, error) {
+	spf, err := c.Record(domain)
+	if err != nil {
+		return Neutral, []string{}, err
+	}
+	return spf.Verdict(ip)
+}
+
+// Record returns the parsed record of domain from the cache, building it if
+// needed. Temporary failures are not cached.
+func (c *Checker) Record(domain string) (*SPF, error) {
+	key := canonicalName(domain)
+	c.mu.Lock()
+	e, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.spf, e.err
+	}
+	v, err := c.g.do(key, func() (interface{}, error) {
+		log := &queryLog{r: c.r}
+		var res resolver = log
+		if c.budget > 0 {
+			res = NewLimitedResolver(log, 0, 0, c.budget)
+		}
+		spf, err := New(domain, res, c.opts...)
+		ttl := c.recordTTL(log)
+		if err != nil {
+			if isTemporary(err) || !(errors.Is(err, NoSPFRecordPublished) || errors.Is(err, PermanentError)) {
+				return nil, err
+			}
+			if c.negativeTTL < ttl {
+				ttl = c.negativeTTL
+			}
+			c.store(key, checkerEntry{err: err, expires: c.now().Add(ttl)})
+			return nil, err
+		}
+		c.store(key, checkerEntry{spf: &spf, expires: c.now().Add(ttl)})
+		return &spf, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*SPF), nil
+}
+
+// Purge drops domain from the cache.
+func (c *Checker) Purge(domain string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cache, canonicalName(domain))
+}
+
+// store adds e to the cache, dropping expired entries and, when the cache is
+// full, the entry closest to expiry.
+func (c *Checker) store(key string, e checkerEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	var oldest string
+	for k, v := range c.cache {
+		if !now.Before(v.expires) {
+			delete(c.cache, k)
+			continue
+		}
+		if oldest == "" || v.expires.Before(c.cache[oldest].expires) {
+			oldest = k
+		}
+	}
+	if _, ok := c.cache[key]; !ok && c.maxEntries > 0 && len(c.cache) >= c.maxEntries {
+		delete(c.cache, oldest)
+	}
+	c.cache[key] = e
+}
+
+// recordTTL returns the shortest TTL of the answers to the queries of log.
+// Answers without a TTL, like negative ones, do not shorten it.
+func (c *Checker) recordTTL(log *queryLog) time.Duration {
+	t, ok := c.r.(ttlResolver)
+	if !ok {
+		return c.ttl
+	}
+	ttl := time.Duration(-1)
+	for _, q := range log.made() {
+		if v, ok := t.TTL(q.domain, q.rtype); ok {
+			if d := time.Duration(v) * time.Second; ttl < 0 || d < ttl {
+				ttl = d
+			}
+		}
+	}
+	if ttl < 0 {
+		return c.ttl
+	}
+	return ttl
+}
+
+type loggedQuery struct {
+	domain string
+	rtype  string
+}
+
+// queryLog passes queries on and remembers which answers were asked for.
+// An A query stands for both the A and the AAAA answers.
+type queryLog struct {
+	r       resolver
+	mu      sync.Mutex
+	queries []loggedQuery
+}
+
+func (l *queryLog) add(domain string, rtypes ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rtype := range rtypes {
+		l.queries = append(l.queries, loggedQuery{domain: domain, rtype: rtype})
+	}
+}
+
+func (l *queryLog) made() []loggedQuery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]loggedQuery(nil), l.queries...)
+}
+
+func (l *queryLog) TextRecord(domain string) ([]string, error) {
+	l.add(domain, "TXT")
+	return l.r.TextRecord(domain)
+}
+
+func (l *queryLog) ARecord(domain string) ([]net.IP, error) {
+	l.add(domain, "A", "AAAA")
+	return l.r.ARecord(domain)
+}
+
+func (l *queryLog) MXRecord(domain string) ([]*net.MX, error) {
+	l.add(domain, "MX")
+	return l.r.MXRecord(domain)
+}
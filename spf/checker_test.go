@@ -0,0 +1,146 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	counter := &countingResolver{resolver: loadTestZone(t), queries: make(map[string]int)}
+	c := NewChecker(counter)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q, m, err := c.Check("test.com", net.ParseIP("172.16.3.4"))
+			if err != nil {
+				t.Errorf("check should not have failed but got %q", err)
+			}
+			if q != Pass || !reflect.DeepEqual(m, []string{"include:_spf.test.com", "ip4:172.16.0.0/16"}) {
+				t.Errorf("wrong verdict %s %v", q, m)
+			}
+		}()
+	}
+	wg.Wait()
+	if counter.queries["TXT test.com"] != 1 {
+		t.Errorf("record should have been fetched once got %d", counter.queries["TXT test.com"])
+	}
+
+	// the address of mx.test.com has the shortest TTL, 300 seconds
+	now = now.Add(4 * time.Minute)
+	c.Check("test.com", net.ParseIP("10.0.0.1"))
+	if counter.queries["TXT test.com"] != 1 {
+		t.Errorf("record should have been cached for its TTL")
+	}
+	now = now.Add(2 * time.Minute)
+	if q, _, _ := c.Check("test.com", net.ParseIP("10.0.0.1")); q != Fail {
+		t.Errorf("wrong verdict wanted fail got %s", q)
+	}
+	if counter.queries["TXT test.com"] != 2 {
+		t.Errorf("record should have been fetched again after its TTL got %d", counter.queries["TXT test.com"])
+	}
+}
+
+func TestCheckerMaxEntries(t *testing.T) {
+	c := NewChecker(loadTestZone(t), WithMaxCacheEntries(1), WithCacheTTL(time.Minute))
+	for _, d := range []string{"test.com", "_spf.test.com", "anything.wild.test.com"} {
+		if _, err := c.Record(d); err != nil {
+			t.Fatalf("building %s should not have failed but got %q", d, err)
+		}
+	}
+	if len(c.cache) != 1 {
+		t.Errorf("cache should hold a single entry got %d", len(c.cache))
+	}
+	if _, err := c.Record("missing.test.com"); err == nil {
+		t.Errorf("missing record should have failed")
+	}
+}
+
+func TestCheckerLoops(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"self.com": []string{"v=spf1 include:self.com -all"},
+			"a.com":    []string{"v=spf1 ip4:10.0.0.1 include:b.com -all"},
+			"b.com":    []string{"v=spf1 include:a.com -all"},
+		},
+	}
+	c := NewChecker(res)
+	for _, domain := range []string{"self.com", "a.com", "b.com"} {
+		if _, _, err := c.Check(domain, net.ParseIP("10.0.0.1")); !errors.Is(err, PermanentError) {
+			t.Errorf("%s loops and should be a permerror got %v", domain, err)
+		}
+	}
+}
+
+func TestCheckerNegativeCache(t *testing.T) {
+	counter := &countingResolver{resolver: loadTestZone(t), queries: make(map[string]int)}
+	c := NewChecker(counter, WithNegativeCacheTTL(30*time.Second))
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Record("missing.test.com"); !errors.Is(err, NoSPFRecordPublished) {
+			t.Fatalf("missing record should have failed got %v", err)
+		}
+	}
+	if counter.queries["TXT missing.test.com"] != 1 {
+		t.Errorf("missing record should have been cached got %d queries", counter.queries["TXT missing.test.com"])
+	}
+	now = now.Add(time.Minute)
+	c.Record("missing.test.com")
+	if counter.queries["TXT missing.test.com"] != 2 {
+		t.Errorf("missing record should have been fetched again after the negative TTL")
+	}
+
+	flaky := &countingResolver{resolver: temporaryResolver{}, queries: make(map[string]int)}
+	c = NewChecker(flaky)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Record("test.com"); !errors.Is(err, TemporaryError) {
+			t.Fatalf("temporary failure should have been returned got %v", err)
+		}
+	}
+	if flaky.queries["TXT test.com"] != 2 {
+		t.Errorf("temporary failures should not be cached got %d queries", flaky.queries["TXT test.com"])
+	}
+}
+
+type temporaryResolver struct {
+	MockResolver
+}
+
+func (temporaryResolver) TextRecord(domain string) ([]string, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: domain, IsTemporary: true}
+}
+
+func TestCheckerExpiredEntries(t *testing.T) {
+	c := NewChecker(loadTestZone(t), WithCacheTTL(time.Minute))
+	if c.maxEntries != DefaultMaxCacheEntries {
+		t.Errorf("cache should be bounded by default got %d", c.maxEntries)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.Record("_spf.test.com")
+	now = now.Add(time.Hour)
+	c.Record("test.com")
+	if _, ok := c.cache["_spf.test.com"]; ok || len(c.cache) != 1 {
+		t.Errorf("expired entries should have been dropped on insert got %d entries", len(c.cache))
+	}
+}
+
+func TestCheckerQueryBudget(t *testing.T) {
+	c := NewChecker(loadTestZone(t), WithQueryLimits(0, 0, 2))
+	if _, err := c.Record("test.com"); !errors.Is(err, QueryBudgetExhausted) {
+		t.Errorf("record needing more queries than the budget should have failed got %v", err)
+	}
+	if _, err := c.Record("_spf.test.com"); err != nil {
+		t.Errorf("every record should get its own budget but got %q", err)
+	}
+}
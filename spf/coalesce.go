@@ -0,0 +1,124 @@
+package spf
+
+import (
+	"net"
+	"sync"
+)
+
+// WithParallelLookups makes Parse resolve all terms of a record at once
+// instead of one after the other. Mechanisms stay in record order so the
+// verdict is unchanged, but lookups for terms that evaluation would never
+// reach are made as well. Observers get called from several goroutines.
+func WithParallelLookups() Option {
+	return func(spf *SPF) {
+		spf.parallel = true
+	}
+}
+
+type flight struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// flightGroup runs a function once per key at a time; concurrent callers
+// with the same key wait for and share the first caller's result.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+	// joined is called when a caller starts waiting for a flight in progress.
+	joined func(key string)
+}
+
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		if g.joined != nil {
+			g.joined(key)
+		}
+		f.wg.Wait()
+		return f.val, f.err
+	}
+	f := &flight{}
+	f.wg.Add(1)
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.val, f.err = fn()
+	f.wg.Done()
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	return f.val, f.err
+}
+
+// CoalescingResolver merges identical queries that are in flight at the same
+// time into a single query to the wrapped resolver.
+type CoalescingResolver struct {
+	r resolver
+	g flightGroup
+}
+
+func NewCoalescingResolver(res resolver) *CoalescingResolver {
+	return &CoalescingResolver{r: res}
+}
+
+func (c *CoalescingResolver) TextRecord(domain string) ([]string, error) {
+	v, err := c.g.do(replayKey(domain, "TXT"), func() (interface{}, error) {
+		return c.r.TextRecord(domain)
+	})
+	txt, _ := v.([]string)
+	return append([]string(nil), txt...), err
+}
+
+func (c *CoalescingResolver) TextStrings(domain string) ([][]string, error) {
+	v, err := c.g.do(replayKey(domain, "TXT strings"), func() (interface{}, error) {
+		return textStrings(c.r, domain)
+	})
+	txt, _ := v.([][]string)
+	return append([][]string(nil), txt...), err
+}
+
+func (c *CoalescingResolver) SPFTypeRecord(domain string) ([]string, error) {
+	v, err := c.g.do(replayKey(domain, "SPF"), func() (interface{}, error) {
+		return spfTypeRecord(c.r, domain)
+	})
+	spf, _ := v.([]string)
+	return append([]string(nil), spf...), err
+}
+
+func (c *CoalescingResolver) ARecord(domain string) ([]net.IP, error) {
+	v, err := c.g.do(replayKey(domain, "A"), func() (interface{}, error) {
+		return c.r.ARecord(domain)
+	})
+	ips, _ := v.([]net.IP)
+	return append([]net.IP(nil), ips...), err
+}
+
+func (c *CoalescingResolver) MXRecord(domain string) ([]*net.MX, error) {
+	v, err := c.g.do(replayKey(domain, "MX"), func() (interface{}, error) {
+		return c.r.MXRecord(domain)
+	})
+	mxs, _ := v.([]*net.MX)
+	return append([]*net.MX(nil), mxs...), err
+}
+
+func (c *CoalescingResolver) NSRecord(domain string) ([]*net.NS, error) {
+	v, err := c.g.do(replayKey(domain, "NS"), func() (interface{}, error) {
+		return nsRecord(c.r, domain)
+	})
+	ns, _ := v.([]*net.NS)
+	return append([]*net.NS(nil), ns...), err
+}
+
+func (c *CoalescingResolver) TTL(domain string, rtype string) (uint32, bool) {
+	if t, ok := c.r.(ttlResolver); ok {
+		return t.TTL(domain, rtype)
+	}
+	return 0, false
+}
@@ -0,0 +1,199 @@
+package spf
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type countingResolver struct {
+	resolver
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+func (c *countingResolver) count(key string) {
+	c.mu.Lock()
+	c.queries[key]++
+	c.mu.Unlock()
+}
+
+func (c *countingResolver) TextRecord(domain string) ([]string, error) {
+	c.count("TXT " + domain)
+	return c.resolver.TextRecord(domain)
+}
+
+func (c *countingResolver) ARecord(domain string) ([]net.IP, error) {
+	c.count("A " + domain)
+	return c.resolver.ARecord(domain)
+}
+
+func TestParallelLookups(t *testing.T) {
+	z := loadTestZone(t)
+	sequential, err := New("test.com", z)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	parallel, err := New("test.com", z, WithParallelLookups())
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	terms := func(spf SPF) (terms []string) {
+		spf.walk(func(path []string, m Mechanism) {
+			terms = append(terms, fmt.Sprint(path, termOf(m), networksOf(m)))
+		})
+		return
+	}
+	if !reflect.DeepEqual(terms(sequential), terms(parallel)) {
+		t.Errorf("parallel parsing changed the mechanisms wanted %v got %v",
+			terms(sequential), terms(parallel))
+	}
+	o := NewOverlayResolver(z)
+	o.SetTXT("test.com", "v=spf1 a:missing.test.com include:missing.test.com include:gone.test.com -all")
+	_, err = New("test.com", o, WithParallelLookups())
+	if err == nil || !strings.HasPrefix(err.Error(), "permanent spf error - include:missing.test.com:") {
+		t.Errorf("expected the error of the first term got %v", err)
+	}
+}
+
+// orderedResolver holds back the address of first.test.com until the record
+// of second.test.com was answered, so parallel parsing finishes the terms
+// out of record order.
+type orderedResolver struct {
+	MockResolver
+	once     sync.Once
+	answered chan struct{}
+}
+
+func (o *orderedResolver) TextRecord(domain string) ([]string, error) {
+	txt, err := o.MockResolver.TextRecord(domain)
+	if domain == "second.test.com" {
+		o.once.Do(func() { close(o.answered) })
+	}
+	return txt, err
+}
+
+func (o *orderedResolver) ARecord(domain string) ([]net.IP, error) {
+	if domain == "first.test.com" {
+		<-o.answered
+	}
+	return o.MockResolver.ARecord(domain)
+}
+
+func TestParallelLookupsOrder(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":        []string{"v=spf1 -a:first.test.com include:second.test.com ~all"},
+			"second.test.com": []string{"v=spf1 ip4:10.0.0.0/8 -all"},
+		},
+		aDomains: aDomainPair{
+			"first.test.com": {net.ParseIP("10.0.0.1")},
+		},
+	}
+	sequential, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	parallel, err := New("test.com", &orderedResolver{MockResolver: res, answered: make(chan struct{})}, WithParallelLookups())
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "192.0.2.1"} {
+		wantQ, wantChain, wantErr := sequential.Verdict(net.ParseIP(ip))
+		q, chain, err := parallel.Verdict(net.ParseIP(ip))
+		if q != wantQ || !reflect.DeepEqual(chain, wantChain) || err != wantErr {
+			t.Errorf("parallel verdict for %s differs wanted %s %v %v got %s %v %v", ip, wantQ, wantChain, wantErr, q, chain, err)
+		}
+	}
+	if q, chain, _ := parallel.Verdict(net.ParseIP("10.0.0.1")); q != Fail || !reflect.DeepEqual(chain, []string{"-a:first.test.com"}) {
+		t.Errorf("first matching term should decide got %s %v", q, chain)
+	}
+}
+
+// blockingResolver answers every TXT and SPF query only once release is
+// closed, telling started about each query that reached it.
+type blockingResolver struct {
+	MockResolver
+	mu      sync.Mutex
+	calls   int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingResolver) block() {
+	b.mu.Lock()
+	b.calls++
+	b.mu.Unlock()
+	b.started <- struct{}{}
+	<-b.release
+}
+
+func (b *blockingResolver) TextRecord(domain string) ([]string, error) {
+	b.block()
+	return []string{"v=spf1 -all"}, nil
+}
+
+func (b *blockingResolver) TextStrings(domain string) ([][]string, error) {
+	b.block()
+	return [][]string{{"v=spf1 ", "-all"}}, nil
+}
+
+func (b *blockingResolver) SPFTypeRecord(domain string) ([]string, error) {
+	b.block()
+	return []string{"v=spf1 -all"}, nil
+}
+
+func TestCoalescingResolver(t *testing.T) {
+	const n = 5
+	TestTable := []struct {
+		testCase string
+		query    func(c *CoalescingResolver) (interface{}, error)
+		want     interface{}
+	}{
+		{"TXT", func(c *CoalescingResolver) (interface{}, error) { return c.TextRecord("test.com") }, []string{"v=spf1 -all"}},
+		{"TXT strings", func(c *CoalescingResolver) (interface{}, error) { return c.TextStrings("test.com") }, [][]string{{"v=spf1 ", "-all"}}},
+		{"SPF", func(c *CoalescingResolver) (interface{}, error) { return c.SPFTypeRecord("test.com") }, []string{"v=spf1 -all"}},
+	}
+	for _, test := range TestTable {
+		b := &blockingResolver{started: make(chan struct{}, n), release: make(chan struct{})}
+		c := NewCoalescingResolver(b)
+		joined := make(chan struct{}, n)
+		c.g.joined = func(string) { joined <- struct{}{} }
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := test.query(c)
+				if err != nil || !reflect.DeepEqual(got, test.want) {
+					t.Errorf("%s: wrong shared answer %v %v", test.testCase, got, err)
+				}
+			}()
+		}
+		// every caller either reaches the backend or joins a flight
+		for i := 0; i < n; i++ {
+			select {
+			case <-b.started:
+			case <-joined:
+			}
+		}
+		close(b.release)
+		wg.Wait()
+		if b.calls != 1 {
+			t.Errorf("%s: concurrent queries should have been merged into one got %d", test.testCase, b.calls)
+		}
+		if _, err := test.query(c); err != nil || b.calls != 2 {
+			t.Errorf("%s: later queries should not reuse the old answer got %d calls", test.testCase, b.calls)
+		}
+	}
+}
+
+func (c *countingResolver) TTL(domain string, rtype string) (uint32, bool) {
+	if t, ok := c.resolver.(ttlResolver); ok {
+		return t.TTL(domain, rtype)
+	}
+	return 0, false
+}
@@ -0,0 +1,58 @@
+package spf
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+)
+
+// RangeDifference is a range of addresses that two records give different
+// results for. ChainA and ChainB are the terms leading to the deciding term
+// of each record, empty when no term matched.
+type RangeDifference struct {
+	From     netip.Addr
+	To       netip.Addr
+	Prefixes []netip.Prefix
+	A        Qualifier
+	ChainA   []string
+	B        Qualifier
+	ChainB   []string
+}
+
+// Compare returns every range of addresses for which a and b give different
+// results, in address order with IPv4 first. Both records have to be built
+// by New so all their lookups are resolved. Ranges with the same results and
+// deciding terms on both sides are merged. No differences means the records
+// are equivalent. The first evaluation error of either record is returned
+// together with the differences found before it.
+func Compare(a *SPF, b *SPF) (diffs []RangeDifference, errRtn error) {
+	prefixes := append(treePrefixes(a), treePrefixes(b)...)
+	for _, r := range elementaryIntervals(prefixes) {
+		qa, chainA, err := verdictAt(a, r.From)
+		if err != nil {
+			errRtn = fmt.Errorf("%w - first record", err)
+			break
+		}
+		qb, chainB, err := verdictAt(b, r.From)
+		if err != nil {
+			errRtn = fmt.Errorf("%w - second record", err)
+			break
+		}
+		if qa == qb {
+			continue
+		}
+		if n := len(diffs); n > 0 {
+			last := &diffs[n-1]
+			if last.To.Next() == r.From && last.A == qa && last.B == qb &&
+				reflect.DeepEqual(last.ChainA, chainA) && reflect.DeepEqual(last.ChainB, chainB) {
+				last.To = r.To
+				continue
+			}
+		}
+		diffs = append(diffs, RangeDifference{From: r.From, To: r.To, A: qa, ChainA: chainA, B: qb, ChainB: chainB})
+	}
+	for i := range diffs {
+		diffs[i].Prefixes = addrInterval{From: diffs[i].From, To: diffs[i].To}.Prefixes()
+	}
+	return
+}
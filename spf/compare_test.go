@@ -0,0 +1,71 @@
+package spf
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"a.com":      []string{"v=spf1 ip4:10.0.0.0/24 ip4:10.0.1.0/24 include:_spf.a.com ip6:2001:db8::/32 -all"},
+			"_spf.a.com": []string{"v=spf1 ip4:192.0.2.0/24 -all"},
+			"same.com":   []string{"v=spf1 ip4:10.0.0.0/23 ip4:192.0.2.0/25 ip4:192.0.2.128/25 ip6:2001:db8::/32 -all"},
+			"b.com":      []string{"v=spf1 ip4:10.0.0.0/23 ~ip4:192.0.2.64/26 ip4:192.0.2.0/24 -all"},
+		},
+	}
+	build := func(domain string) *SPF {
+		spf, err := New(domain, res)
+		if err != nil {
+			t.Fatalf("creating SPF should not have failed but got %q", err)
+		}
+		return &spf
+	}
+	a := build("a.com")
+
+	if diffs, err := Compare(a, build("same.com")); err != nil || len(diffs) != 0 {
+		t.Errorf("equivalent records should not differ got %+v %v", diffs, err)
+	}
+
+	want := []RangeDifference{
+		{
+			From:     netip.MustParseAddr("192.0.2.64"),
+			To:       netip.MustParseAddr("192.0.2.127"),
+			Prefixes: []netip.Prefix{netip.MustParsePrefix("192.0.2.64/26")},
+			A:        Pass,
+			ChainA:   []string{"include:_spf.a.com", "ip4:192.0.2.0/24"},
+			B:        Softfail,
+			ChainB:   []string{"~ip4:192.0.2.64/26"},
+		},
+		{
+			From:     netip.MustParseAddr("2001:db8::"),
+			To:       netip.MustParseAddr("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"),
+			Prefixes: []netip.Prefix{netip.MustParsePrefix("2001:db8::/32")},
+			A:        Pass,
+			ChainA:   []string{"ip6:2001:db8::/32"},
+			B:        Fail,
+			ChainB:   []string{"-all"},
+		},
+	}
+	got, err := Compare(a, build("b.com"))
+	if err != nil {
+		t.Fatalf("comparing should not have failed but got %q", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong differences wanted\n%+v\ngot\n%+v", want, got)
+	}
+}
+
+func TestIntervalPrefixes(t *testing.T) {
+	r := addrInterval{From: netip.MustParseAddr("10.0.0.1"), To: netip.MustParseAddr("10.0.0.8")}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.2/31"),
+		netip.MustParsePrefix("10.0.0.4/30"),
+		netip.MustParsePrefix("10.0.0.8/32"),
+	}
+	if got := r.Prefixes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong prefixes wanted %v got %v", want, got)
+	}
+}
@@ -0,0 +1,142 @@
+package spf
+
+import (
+	"sort"
+	"strings"
+)
+
+// TermCost is the lookup cost of a single term. Own is what the term costs
+// itself, Subtree adds everything reached through it when it is an include
+// or redirect. Networks is the number of networks the term and its subtree
+// contribute. A term is Flattenable when its subtree can be replaced by
+// ip4 and ip6 terms, that is it holds no ptr, exists or macro. Provider is
+// set for include and redirect terms of a known provider.
+type TermCost struct {
+	Term        string
+	Domain      string
+	Path        []string
+	Own         int
+	Subtree     int
+	Networks    int
+	Flattenable bool
+	Provider    *Provider
+	Children    []TermCost
+}
+
+// LookupCost returns the cost of every term of the record, in record order.
+func (spf *SPF) LookupCost() []TermCost {
+	return termCosts(spf, nil)
+}
+
+// LookupCost returns the cost of the include term with the terms of the
+// included record as children.
+func (i Include) LookupCost() TermCost {
+	return branchCost(i.Record, i.spf.Domain, nil, &i.spf, i.Provider)
+}
+
+// FlattenCandidates returns the flattenable include and redirect branches
+// found anywhere in costs, best candidates first: the ones saving the most
+// lookups, then the ones adding the fewest networks.
+func FlattenCandidates(costs []TermCost) (candidates []TermCost) {
+	var collect func(costs []TermCost)
+	collect = func(costs []TermCost) {
+		for _, c := range costs {
+			if name := termName(c.Term); name != "include" && name != "redirect" {
+				continue
+			}
+			if c.Flattenable {
+				candidates = append(candidates, c)
+			}
+			collect(c.Children)
+		}
+	}
+	collect(costs)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Subtree != candidates[j].Subtree {
+			return candidates[i].Subtree > candidates[j].Subtree
+		}
+		return candidates[i].Networks < candidates[j].Networks
+	})
+	return
+}
+
+// recordTerm is a term of a record with the mechanism it was parsed into,
+// nil for terms Parse does not support.
+type recordTerm struct {
+	term string
+	m    Mechanism
+}
+
+// recordTerms returns the terms of the record after the version, in record
+// order.
+func recordTerms(spf *SPF) (terms []recordTerm) {
+	next := 0
+	for _, term := range strings.Fields(spf.Record) {
+		if term == "v=spf1" {
+			continue
+		}
+		var m Mechanism
+		if isRedirectModifier(term) && spf.Redirect != nil {
+			m = *spf.Redirect
+		} else if next < len(spf.Mechanisms) && termOf(spf.Mechanisms[next]) == term {
+			m = spf.Mechanisms[next]
+			next++
+		}
+		terms = append(terms, recordTerm{term: term, m: m})
+	}
+	return
+}
+
+func termCosts(spf *SPF, path []string) (costs []TermCost) {
+	for _, t := range recordTerms(spf) {
+		term, m := t.term, t.m
+		switch v := m.(type) {
+		case Include:
+			costs = append(costs, branchCost(term, spf.Domain, path, &v.spf, v.Provider))
+		case Redirect:
+			provider, _ := spf.providers().ByInclude(v.Domain)
+			costs = append(costs, branchCost(term, spf.Domain, path, &v.spf, provider))
+		default:
+			c := TermCost{
+				Term:        term,
+				Domain:      spf.Domain,
+				Path:        path,
+				Flattenable: termFlattenable(term),
+			}
+			if lookupTerms[termName(term)] {
+				c.Own, c.Subtree = 1, 1
+			}
+			if m != nil {
+				c.Networks = len(networksOf(m))
+			}
+			costs = append(costs, c)
+		}
+	}
+	return
+}
+
+func branchCost(term string, domain string, path []string, spf *SPF, provider *Provider) TermCost {
+	c := TermCost{
+		Term:        term,
+		Domain:      domain,
+		Path:        path,
+		Own:         1,
+		Subtree:     1,
+		Flattenable: termFlattenable(term),
+		Provider:    provider,
+		Children:    termCosts(spf, append(path[:len(path):len(path)], term)),
+	}
+	for _, child := range c.Children {
+		c.Subtree += child.Subtree
+		c.Networks += child.Networks
+		c.Flattenable = c.Flattenable && child.Flattenable
+	}
+	return c
+}
+
+// termFlattenable reports whether the addresses a term matches are fixed
+// once its lookups are done.
+func termFlattenable(term string) bool {
+	name := termName(term)
+	return name != "ptr" && name != "exists" && !strings.Contains(term, "%")
+}
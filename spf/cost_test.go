@@ -0,0 +1,83 @@
+package spf
+
+import (
+	"net"
+	"testing"
+)
+
+func TestLookupCost(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":        []string{"v=spf1 a include:big.test.com include:small.test.com include:dyn.test.com -all"},
+			"big.test.com":    []string{"v=spf1 ip4:10.0.0.0/8 include:nested.test.com a mx ~all"},
+			"nested.test.com": []string{"v=spf1 ip4:172.16.0.0/16 ip4:172.17.0.0/16 -all"},
+			"small.test.com":  []string{"v=spf1 ip4:192.0.2.0/24 a:small.test.com -all"},
+			"dyn.test.com":    []string{"v=spf1 exists:%{i}.dyn.test.com -all"},
+		},
+		aDomains: aDomainPair{
+			"test.com":       {net.ParseIP("10.5.5.5")},
+			"big.test.com":   {net.ParseIP("10.6.6.6")},
+			"small.test.com": {net.ParseIP("192.0.2.7")},
+		},
+	}
+	spf, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	costs := spf.LookupCost()
+	TestTable := []struct {
+		testCase string
+		got      TermCost
+		own      int
+		subtree  int
+		networks int
+	}{
+		{"a", costs[0], 1, 1, 1},
+		{"big include", costs[1], 1, 4, 4},
+		{"small include", costs[2], 1, 2, 2},
+		{"exists include", costs[3], 1, 2, 0},
+		{"all", costs[4], 0, 0, 0},
+		{"nested include", costs[1].Children[1], 1, 1, 2},
+	}
+	for _, test := range TestTable {
+		if test.got.Own != test.own || test.got.Subtree != test.subtree || test.got.Networks != test.networks {
+			t.Errorf("%s: wanted own %d subtree %d networks %d got %d %d %d", test.testCase,
+				test.own, test.subtree, test.networks, test.got.Own, test.got.Subtree, test.got.Networks)
+		}
+	}
+	if costs[3].Flattenable {
+		t.Errorf("include with exists should not be flattenable")
+	}
+	if got := costs[1].Children[1].Path; len(got) != 1 || got[0] != "include:big.test.com" {
+		t.Errorf("wrong path of nested include got %q", got)
+	}
+
+	var want = []string{"include:big.test.com", "include:small.test.com", "include:nested.test.com"}
+	candidates := FlattenCandidates(costs)
+	if len(candidates) != len(want) {
+		t.Fatalf("wanted %d candidates got %d", len(want), len(candidates))
+	}
+	for i, c := range candidates {
+		if c.Term != want[i] {
+			t.Errorf("candidate %d should be %q got %q", i, want[i], c.Term)
+		}
+	}
+}
+
+func TestIncludeLookupCost(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"_spf.test.com": []string{"v=spf1 mx ip4:10.0.0.0/8 -all"},
+		},
+		mxDomains: mxDomainPair{"_spf.test.com": {{Host: "mail.test.com", Pref: 10}}},
+		aDomains:  aDomainPair{"mail.test.com": {net.ParseIP("10.1.1.1")}},
+	}
+	i, err := NewInclude("include:_spf.test.com", res)
+	if err != nil {
+		t.Fatalf("creating include should not have failed but got %q", err)
+	}
+	c := i.LookupCost()
+	if c.Own != 1 || c.Subtree != 2 || c.Networks != 2 || len(c.Children) != 3 {
+		t.Errorf("wrong cost got %+v", c)
+	}
+}
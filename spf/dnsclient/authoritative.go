@@ -0,0 +1,70 @@
+package dnsclient
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// AuthoritativeClient sends non-recursive queries straight to nameservers,
+// see spf.CheckPropagation.
+type AuthoritativeClient struct {
+	Port   string
+	client *dns.Client
+}
+
+// NewAuthoritativeClient creates a client querying nameservers on port 53.
+func NewAuthoritativeClient() *AuthoritativeClient {
+	return &AuthoritativeClient{Port: "53", client: &dns.Client{Timeout: 5 * time.Second}}
+}
+
+// TXT returns the TXT records server holds for domain.
+func (c *AuthoritativeClient) TXT(server net.IP, domain string) ([]string, error) {
+	in, err := c.exchange(server, domain, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txt []string
+	for _, rr := range in.Answer {
+		if v, ok := rr.(*dns.TXT); ok {
+			txt = append(txt, strings.Join(v.Txt, ""))
+		}
+	}
+	return txt, nil
+}
+
+// Serial returns the serial of the SOA record server holds for zone.
+func (c *AuthoritativeClient) Serial(server net.IP, zone string) (uint32, error) {
+	in, err := c.exchange(server, zone, dns.TypeSOA)
+	if err != nil {
+		return 0, err
+	}
+	for _, rr := range in.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+	return 0, fmt.Errorf("no SOA record for %s", zone)
+}
+
+// exchange sends a non-recursive query and fails unless the server answers
+// authoritatively.
+func (c *AuthoritativeClient) exchange(server net.IP, domain string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(domain), qtype)
+	m.RecursionDesired = false
+	in, _, err := c.client.Exchange(m, net.JoinHostPort(server.String(), c.Port))
+	if err != nil {
+		return nil, err
+	}
+	if in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("server answered %s", dns.RcodeToString[in.Rcode])
+	}
+	if !in.Authoritative {
+		return nil, fmt.Errorf("server is not authoritative for %s", domain)
+	}
+	return in, nil
+}
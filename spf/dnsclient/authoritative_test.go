@@ -0,0 +1,131 @@
+package dnsclient
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/VenditoKft/spfing/spf"
+	"github.com/miekg/dns"
+)
+
+type authoritativeServer struct {
+	records       map[string]string
+	serial        uint32
+	authoritative bool
+	noSOA         bool
+}
+
+func (s authoritativeServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = s.authoritative
+	q := req.Question[0]
+	switch q.Qtype {
+	case dns.TypeTXT:
+		if v, ok := s.records[q.Name]; ok {
+			m.Answer = append(m.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300},
+				Txt: []string{v[:len(v)/2], v[len(v)/2:]},
+			})
+		}
+	case dns.TypeSOA:
+		if s.noSOA {
+			break
+		}
+		m.Answer = append(m.Answer, &dns.SOA{
+			Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+			Ns:     "ns1.test.com.",
+			Mbox:   "hostmaster.test.com.",
+			Serial: s.serial,
+		})
+	}
+	w.WriteMsg(m)
+}
+
+func serveAuthoritative(t *testing.T, address string, s authoritativeServer) string {
+	pc, err := net.ListenPacket("udp", address)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %s", address, err)
+	}
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: s, NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestAuthoritativeClient(t *testing.T) {
+	addr := serveAuthoritative(t, "127.0.0.1:0", authoritativeServer{
+		records:       map[string]string{"test.com.": "v=spf1 ip4:10.0.0.0/8 -all"},
+		serial:        7,
+		authoritative: true,
+	})
+	_, port, _ := net.SplitHostPort(addr)
+	serveAuthoritative(t, "127.0.0.2:"+port, authoritativeServer{noSOA: true, authoritative: true})
+	serveAuthoritative(t, "127.0.0.3:"+port, authoritativeServer{
+		records: map[string]string{"test.com.": "v=spf1 -all"},
+	})
+	c := NewAuthoritativeClient()
+	c.Port = port
+
+	txt, err := c.TXT(net.ParseIP("127.0.0.1"), "test.com")
+	if err != nil || !reflect.DeepEqual(txt, []string{"v=spf1 ip4:10.0.0.0/8 -all"}) {
+		t.Errorf("wrong TXT answer got %v %v", txt, err)
+	}
+	if serial, err := c.Serial(net.ParseIP("127.0.0.1"), "test.com"); err != nil || serial != 7 {
+		t.Errorf("wrong serial wanted 7 got %d %v", serial, err)
+	}
+	if txt, err := c.TXT(net.ParseIP("127.0.0.2"), "test.com"); err != nil || len(txt) != 0 {
+		t.Errorf("missing record should give no answer got %v %v", txt, err)
+	}
+	if _, err := c.Serial(net.ParseIP("127.0.0.2"), "test.com"); err == nil {
+		t.Errorf("missing SOA should have failed")
+	}
+	if _, err := c.TXT(net.ParseIP("127.0.0.3"), "test.com"); err == nil {
+		t.Errorf("non authoritative answer should have failed")
+	}
+}
+
+func TestCheckPropagation(t *testing.T) {
+	addr := serveAuthoritative(t, "127.0.0.1:0", authoritativeServer{
+		records:       map[string]string{"test.com.": "v=spf1 ip4:10.0.0.0/8 -all"},
+		serial:        2,
+		authoritative: true,
+	})
+	_, port, _ := net.SplitHostPort(addr)
+	serveAuthoritative(t, "127.0.0.2:"+port, authoritativeServer{
+		records:       map[string]string{"test.com.": "v=spf1 -all"},
+		serial:        3,
+		authoritative: true,
+	})
+	c := NewAuthoritativeClient()
+	c.Port = port
+
+	z := spf.NewZoneResolver()
+	err := z.Load(strings.NewReader(`
+$ORIGIN test.com.
+@    NS  ns1
+@    NS  ns2
+@    TXT "v=spf1 ip4:10.0.0.0/8 -all"
+ns1  A   127.0.0.1
+ns2  A   127.0.0.2
+`), "")
+	if err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+	report, err := spf.CheckPropagation("test.com", net.ParseIP("10.1.1.1"), z, c)
+	if err != nil {
+		t.Fatalf("propagation check should not have failed but got %q", err)
+	}
+	if report.Consistent || len(report.Domains) != 1 || len(report.Domains[0].Answers) != 2 {
+		t.Fatalf("expected inconsistent report got %+v", report)
+	}
+	for i, want := range []spf.Qualifier{spf.Pass, spf.Fail} {
+		if a := report.Domains[0].Answers[i]; a.Verdict != want || a.Serial != uint32(i+2) {
+			t.Errorf("wrong answer from %s got %+v", a.Address, a)
+		}
+	}
+}
@@ -0,0 +1,573 @@
+// Package dnsclient holds the parts of spfing that talk DNS on the wire
+// through github.com/miekg/dns, kept out of the core module so that it has
+// no dependencies.
+package dnsclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VenditoKft/spfing/spf"
+	"github.com/miekg/dns"
+)
+
+var ValidationError = errors.New("dnssec validation failed")
+
+const maxChainDepth = 16
+
+type SecurityStatus int
+
+const (
+	Indeterminate SecurityStatus = iota
+	Secure
+	Insecure
+	Bogus
+)
+
+func (s SecurityStatus) String() string {
+	switch s {
+	case Secure:
+		return "secure"
+	case Insecure:
+		return "insecure"
+	case Bogus:
+		return "bogus"
+	default:
+		return "indeterminate"
+	}
+}
+
+// SecuredLookup is one lookup made by DNSSECResolver along with the DNSSEC
+// state of its answer.
+type SecuredLookup struct {
+	Domain string
+	Type   string
+	AD     bool
+	Status SecurityStatus
+	Reason string
+}
+
+// DNSSECResolver queries Server directly with the DO bit set and records, for
+// every answer, the AD flag returned by the server. When trust anchors are
+// given the answers are also validated locally by following the chain of
+// DNSKEY and DS records from the RRSIG signer up to an anchor. Unsigned
+// answers, and empty ones without signed records in the authority section,
+// are reported as Insecure; proving that a delegation is really unsigned via
+// NSEC/NSEC3 is not attempted.
+type DNSSECResolver struct {
+	Server  string
+	anchors []*dns.DS
+	client  *dns.Client
+	tcp     *dns.Client
+	*resolverState
+	// trace collects the lookups of a single Trace call, guarded by mu.
+	trace *[]SecuredLookup
+}
+
+// resolverState is shared by a resolver and the copies Trace makes of it.
+type resolverState struct {
+	mu      sync.Mutex
+	lookups []SecuredLookup
+	ttls    map[string]uint32
+	keys    map[string][]*dns.DNSKEY
+	mxHosts map[string][]string
+}
+
+// NewDNSSECResolver creates a resolver using the server at address
+// (host:port). With no anchors only the AD flag of the server is trusted.
+func NewDNSSECResolver(address string, anchors ...*dns.DS) *DNSSECResolver {
+	return &DNSSECResolver{
+		Server:  address,
+		anchors: anchors,
+		client:  &dns.Client{Timeout: 10 * time.Second},
+		tcp:     &dns.Client{Net: "tcp", Timeout: 10 * time.Second},
+		resolverState: &resolverState{
+			ttls:    make(map[string]uint32),
+			keys:    make(map[string][]*dns.DNSKEY),
+			mxHosts: make(map[string][]string),
+		},
+	}
+}
+
+// Lookups returns the lookups made so far in query order.
+func (r *DNSSECResolver) Lookups() []SecuredLookup {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SecuredLookup(nil), r.lookups...)
+}
+
+// Status returns the weakest status seen for the given name and record type,
+// Indeterminate if it was never looked up.
+func (r *DNSSECResolver) Status(domain string, rtype string) SecurityStatus {
+	status, _ := r.statusOf(domain, rtype)
+	return status
+}
+
+func (r *DNSSECResolver) statusOf(domain string, rtype string) (status SecurityStatus, reason string) {
+	for _, l := range r.Lookups() {
+		if canonicalName(l.Domain) == canonicalName(domain) && l.Type == rtype && l.Status > status {
+			status, reason = l.Status, l.Reason
+		}
+	}
+	return
+}
+
+// SecuredHop is one lookup a verdict was built from with the DNSSEC status
+// of its answer. Chain is set for the lookups behind the chain of the
+// verdict, Term then holds the chain term that needed the lookup, empty for
+// the record of the evaluated domain.
+type SecuredHop struct {
+	Term   string
+	Chain  bool
+	Domain string
+	Type   string
+	Status SecurityStatus
+	Reason string
+}
+
+// Trace builds the record of domain through r, evaluates ip against it and
+// returns the verdict with every lookup made on the way as hops, in query
+// order: the records of all includes and redirects, matching or not, and
+// every address and MX answer. Hops whose data was unsigned or failed
+// validation have status Insecure or Bogus. When the record cannot be built
+// the hops up to the failure are returned with the error.
+func (r *DNSSECResolver) Trace(domain string, ip net.IP, opts ...spf.Option) (spf.Qualifier, []SecuredHop, error) {
+	t := *r
+	t.trace = &[]SecuredLookup{}
+	record, err := spf.New(domain, &t, opts...)
+	r.mu.Lock()
+	hops := make([]SecuredHop, 0, len(*t.trace))
+	for _, l := range *t.trace {
+		hops = append(hops, SecuredHop{Domain: l.Domain, Type: l.Type, Status: l.Status, Reason: l.Reason})
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return spf.Neutral, hops, err
+	}
+	q, chain, err := record.Verdict(ip)
+	if err != nil {
+		return q, hops, err
+	}
+	mark := func(term string, domain string, rtype string) {
+		for i, h := range hops {
+			if !h.Chain && h.Type == rtype && canonicalName(h.Domain) == canonicalName(domain) {
+				hops[i].Term, hops[i].Chain = term, true
+			}
+		}
+	}
+	mark("", record.Domain, "TXT")
+	domain = record.Domain
+	for _, term := range chain {
+		switch name := termName(term); name {
+		case "include", "redirect":
+			domain = term[strings.IndexAny(term, ":=")+1:]
+			mark(term, domain, "TXT")
+		case "a", "mx":
+			d := termDomain(term)
+			if d == "" {
+				d = domain
+			}
+			hosts := []string{d}
+			if name == "mx" {
+				mark(term, d, "MX")
+				r.mu.Lock()
+				hosts = r.mxHosts[canonicalName(d)]
+				r.mu.Unlock()
+			}
+			for _, h := range hosts {
+				mark(term, h, "A")
+				mark(term, h, "AAAA")
+			}
+		}
+	}
+	return q, hops, nil
+}
+
+func (r *DNSSECResolver) TTL(domain string, rtype string) (uint32, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ttl, ok := r.ttls[ttlKey(domain, rtype)]
+	return ttl, ok
+}
+
+func (r *DNSSECResolver) exchange(domain string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(domain), qtype)
+	m.SetEdns0(4096, true)
+	in, _, err := r.client.Exchange(m, r.Server)
+	if err == nil && in.Truncated {
+		in, _, err = r.tcp.Exchange(m, r.Server)
+	}
+	if err != nil {
+		return nil, r.temporaryError(domain, err.Error())
+	}
+	return in, nil
+}
+
+// temporaryError is a failure RFC 7208 treats as temperror: a timeout, an
+// unreachable server or SERVFAIL.
+func (r *DNSSECResolver) temporaryError(domain string, reason string) error {
+	return fmt.Errorf("%w - %w", spf.TemporaryError, &net.DNSError{Err: reason, Name: domain, Server: r.Server, IsTemporary: true})
+}
+
+// lookup runs one query, records its DNSSEC state and returns the answer
+// records of type qtype.
+func (r *DNSSECResolver) lookup(domain string, qtype uint16) ([]dns.RR, error) {
+	in, err := r.exchange(domain, qtype)
+	if err != nil {
+		return nil, err
+	}
+	rtype := dns.TypeToString[qtype]
+	l := SecuredLookup{Domain: domain, Type: rtype, AD: in.AuthenticatedData}
+	switch in.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+		l.Status, l.Reason = r.status(in)
+	default:
+		l.Reason = dns.RcodeToString[in.Rcode]
+	}
+	var answer []dns.RR
+	for _, rr := range in.Answer {
+		if rr.Header().Rrtype == qtype {
+			answer = append(answer, rr)
+		}
+	}
+	r.mu.Lock()
+	r.lookups = append(r.lookups, l)
+	if r.trace != nil {
+		*r.trace = append(*r.trace, l)
+	}
+	if len(answer) > 0 {
+		ttl := answer[0].Header().Ttl
+		for _, rr := range answer[1:] {
+			if rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+		r.ttls[ttlKey(domain, rtype)] = ttl
+	}
+	r.mu.Unlock()
+	switch in.Rcode {
+	case dns.RcodeSuccess:
+		return answer, nil
+	case dns.RcodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	case dns.RcodeServerFailure:
+		return nil, r.temporaryError(domain, dns.RcodeToString[in.Rcode])
+	default:
+		return nil, &net.DNSError{Err: dns.RcodeToString[in.Rcode], Name: domain, Server: r.Server}
+	}
+}
+
+func (r *DNSSECResolver) status(in *dns.Msg) (SecurityStatus, string) {
+	if len(r.anchors) == 0 {
+		if in.AuthenticatedData {
+			return Secure, ""
+		}
+		return Insecure, "AD flag not set"
+	}
+	section := in.Answer
+	if len(section) == 0 {
+		section = in.Ns
+	}
+	sets := rrsets(section)
+	if len(sets) == 0 {
+		return Insecure, "no records to validate in the answer"
+	}
+	status := Secure
+	reason := ""
+	for _, set := range sets {
+		if len(set.sigs) == 0 {
+			if status < Insecure {
+				status, reason = Insecure, fmt.Sprintf("%s %s is unsigned", set.name, dns.TypeToString[set.rtype])
+			}
+			continue
+		}
+		if err := r.validate(set.rrs, set.sigs, 0); err != nil {
+			return Bogus, err.Error()
+		}
+	}
+	return status, reason
+}
+
+type rrset struct {
+	name  string
+	rtype uint16
+	rrs   []dns.RR
+	sigs  []*dns.RRSIG
+}
+
+// rrsets groups a message section by owner and type, attaching the RRSIGs
+// that cover each set.
+func rrsets(section []dns.RR) []*rrset {
+	var sets []*rrset
+	find := func(name string, rtype uint16) *rrset {
+		for _, s := range sets {
+			if s.name == name && s.rtype == rtype {
+				return s
+			}
+		}
+		s := &rrset{name: name, rtype: rtype}
+		sets = append(sets, s)
+		return s
+	}
+	for _, rr := range section {
+		name := strings.ToLower(rr.Header().Name)
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			s := find(name, sig.TypeCovered)
+			s.sigs = append(s.sigs, sig)
+			continue
+		}
+		s := find(name, rr.Header().Rrtype)
+		s.rrs = append(s.rrs, rr)
+	}
+	return sets
+}
+
+// validate checks that one of sigs is a valid signature over set made by a
+// key of a zone that chains up to a trust anchor.
+func (r *DNSSECResolver) validate(set []dns.RR, sigs []*dns.RRSIG, depth int) error {
+	if len(set) == 0 {
+		return fmt.Errorf("%w - signatures without records", ValidationError)
+	}
+	errRtn := fmt.Errorf("%w - no usable signature for %s", ValidationError, set[0].Header().Name)
+	for _, sig := range sigs {
+		if !sig.ValidityPeriod(time.Now()) {
+			errRtn = fmt.Errorf("%w - signature by %s outside validity period", ValidationError, sig.SignerName)
+			continue
+		}
+		keys, err := r.zoneKeys(sig.SignerName, depth)
+		if err != nil {
+			errRtn = err
+			continue
+		}
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			if err := sig.Verify(key, set); err == nil {
+				return nil
+			}
+			errRtn = fmt.Errorf("%w - bad signature by %s key %d", ValidationError, sig.SignerName, sig.KeyTag)
+		}
+	}
+	return errRtn
+}
+
+// zoneKeys returns the validated DNSKEY set of zone.
+func (r *DNSSECResolver) zoneKeys(zone string, depth int) ([]*dns.DNSKEY, error) {
+	zone = dns.Fqdn(strings.ToLower(zone))
+	if depth > maxChainDepth {
+		return nil, fmt.Errorf("%w - chain too deep at %s", ValidationError, zone)
+	}
+	r.mu.Lock()
+	keys, ok := r.keys[zone]
+	r.mu.Unlock()
+	if ok {
+		return keys, nil
+	}
+	var ds []*dns.DS
+	for _, a := range r.anchors {
+		if strings.EqualFold(dns.Fqdn(a.Hdr.Name), zone) {
+			ds = append(ds, a)
+		}
+	}
+	if len(ds) == 0 {
+		if zone == "." {
+			return nil, fmt.Errorf("%w - no trust anchor covers the chain", ValidationError)
+		}
+		in, err := r.exchange(zone, dns.TypeDS)
+		if err != nil {
+			return nil, err
+		}
+		var set []dns.RR
+		var sigs []*dns.RRSIG
+		for _, rr := range in.Answer {
+			switch v := rr.(type) {
+			case *dns.DS:
+				ds = append(ds, v)
+				set = append(set, v)
+			case *dns.RRSIG:
+				if v.TypeCovered == dns.TypeDS {
+					sigs = append(sigs, v)
+				}
+			}
+		}
+		if len(ds) == 0 {
+			return nil, fmt.Errorf("%w - no DS for %s", ValidationError, zone)
+		}
+		if err := r.validate(set, sigs, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	in, err := r.exchange(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	var set []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range in.Answer {
+		switch v := rr.(type) {
+		case *dns.DNSKEY:
+			keys = append(keys, v)
+			set = append(set, v)
+		case *dns.RRSIG:
+			if v.TypeCovered == dns.TypeDNSKEY {
+				sigs = append(sigs, v)
+			}
+		}
+	}
+	for _, sig := range sigs {
+		if !sig.ValidityPeriod(time.Now()) {
+			continue
+		}
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || !matchesDS(key, ds) {
+				continue
+			}
+			if sig.Verify(key, set) == nil {
+				r.mu.Lock()
+				r.keys[zone] = keys
+				r.mu.Unlock()
+				return keys, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w - DNSKEY set of %s is not signed by a trusted key", ValidationError, zone)
+}
+
+func matchesDS(key *dns.DNSKEY, ds []*dns.DS) bool {
+	for _, d := range ds {
+		if d.KeyTag != key.KeyTag() || d.Algorithm != key.Algorithm {
+			continue
+		}
+		if k := key.ToDS(d.DigestType); k != nil && strings.EqualFold(k.Digest, d.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *DNSSECResolver) TextRecord(domain string) ([]string, error) {
+	rrs, err := r.lookup(domain, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txt []string
+	for _, rr := range rrs {
+		txt = append(txt, strings.Join(rr.(*dns.TXT).Txt, ""))
+	}
+	return txt, nil
+}
+
+// TextStrings returns every TXT record of domain split into its strings.
+func (r *DNSSECResolver) TextStrings(domain string) ([][]string, error) {
+	rrs, err := r.lookup(domain, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txt [][]string
+	for _, rr := range rrs {
+		txt = append(txt, rr.(*dns.TXT).Txt)
+	}
+	return txt, nil
+}
+
+// SPFTypeRecord returns the records of the deprecated SPF type of domain.
+func (r *DNSSECResolver) SPFTypeRecord(domain string) ([]string, error) {
+	rrs, err := r.lookup(domain, dns.TypeSPF)
+	if err != nil {
+		return nil, err
+	}
+	var records []string
+	for _, rr := range rrs {
+		records = append(records, strings.Join(rr.(*dns.SPF).Txt, ""))
+	}
+	return records, nil
+}
+
+func (r *DNSSECResolver) ARecord(domain string) ([]net.IP, error) {
+	a, err := r.lookup(domain, dns.TypeA)
+	if err != nil {
+		return nil, err
+	}
+	aaaa, err := r.lookup(domain, dns.TypeAAAA)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, rr := range append(a, aaaa...) {
+		switch v := rr.(type) {
+		case *dns.A:
+			ips = append(ips, v.A)
+		case *dns.AAAA:
+			ips = append(ips, v.AAAA)
+		}
+	}
+	return ips, nil
+}
+
+func (r *DNSSECResolver) MXRecord(domain string) ([]*net.MX, error) {
+	rrs, err := r.lookup(domain, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	var mxs []*net.MX
+	var hosts []string
+	for _, rr := range rrs {
+		mx := rr.(*dns.MX)
+		mxs = append(mxs, &net.MX{Host: mx.Mx, Pref: mx.Preference})
+		hosts = append(hosts, mx.Mx)
+	}
+	r.mu.Lock()
+	r.mxHosts[canonicalName(domain)] = hosts
+	r.mu.Unlock()
+	return mxs, nil
+}
+
+func (r *DNSSECResolver) NSRecord(domain string) ([]*net.NS, error) {
+	rrs, err := r.lookup(domain, dns.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+	var ns []*net.NS
+	for _, rr := range rrs {
+		ns = append(ns, &net.NS{Host: rr.(*dns.NS).Ns})
+	}
+	return ns, nil
+}
+
+func canonicalName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func ttlKey(domain string, rtype string) string {
+	return rtype + " " + canonicalName(domain)
+}
+
+// termName returns the lower case name of a mechanism or modifier without
+// its qualifier.
+func termName(term string) string {
+	term = strings.ToLower(strings.TrimLeft(term, "+-~?"))
+	if i := strings.IndexAny(term, ":/="); i >= 0 {
+		return term[:i]
+	}
+	return term
+}
+
+// termDomain returns the domain of an a or mx term, empty when the term
+// uses the domain of its record.
+func termDomain(term string) string {
+	i := strings.IndexByte(term, ':')
+	if i < 0 {
+		return ""
+	}
+	d := term[i+1:]
+	if j := strings.IndexByte(d, '/'); j >= 0 {
+		d = d[:j]
+	}
+	return d
+}
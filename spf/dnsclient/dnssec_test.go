@@ -0,0 +1,233 @@
+package dnsclient
+
+import (
+	"crypto"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/VenditoKft/spfing/spf"
+	"github.com/miekg/dns"
+)
+
+type signedZone struct {
+	key     *dns.DNSKEY
+	signer  crypto.Signer
+	records map[string][]dns.RR
+}
+
+func newSignedZone(t *testing.T) *signedZone {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "test.com.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("generating key should not have failed but got %q", err)
+	}
+	z := &signedZone{key: key, signer: priv.(crypto.Signer), records: make(map[string][]dns.RR)}
+	z.add(t, true, key)
+	z.add(t, true, mustRR(t, `test.com. 300 IN TXT "v=spf1 a include:unsigned.test.com -all"`))
+	z.add(t, true, mustRR(t, "test.com. 300 IN A 192.168.1.1"))
+	z.add(t, false, mustRR(t, `unsigned.test.com. 300 IN TXT "v=spf1 ip4:10.0.0.0/8 -all"`))
+	z.add(t, true, mustRR(t, `bogus.test.com. 300 IN TXT "v=spf1 -all"`))
+	z.records["bogus.test.com. TXT"][0] = mustRR(t, `bogus.test.com. 300 IN TXT "v=spf1 +all"`)
+	return z
+}
+
+func mustRR(t *testing.T, s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("bad test record %q: %s", s, err)
+	}
+	return rr
+}
+
+func (z *signedZone) add(t *testing.T, sign bool, rr dns.RR) {
+	k := rr.Header().Name + " " + dns.TypeToString[rr.Header().Rrtype]
+	z.records[k] = append(z.records[k], rr)
+	if !sign {
+		return
+	}
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rr.Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: rr.Header().Ttl},
+		Algorithm:  z.key.Algorithm,
+		SignerName: "test.com.",
+		KeyTag:     z.key.KeyTag(),
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := sig.Sign(z.signer, []dns.RR{rr}); err != nil {
+		t.Fatalf("signing should not have failed but got %q", err)
+	}
+	z.records[k] = append(z.records[k], sig)
+}
+
+func (z *signedZone) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	q := req.Question[0]
+	if q.Name == "servfail.test.com." {
+		m.Rcode = dns.RcodeServerFailure
+	}
+	m.Answer = z.records[q.Name+" "+dns.TypeToString[q.Qtype]]
+	for _, rr := range m.Answer {
+		if _, ok := rr.(*dns.RRSIG); ok && q.Name != "bogus.test.com." {
+			m.AuthenticatedData = true
+		}
+	}
+	w.WriteMsg(m)
+}
+
+func serveSignedZone(t *testing.T) (*signedZone, string) {
+	z := newSignedZone(t)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %s", err)
+	}
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: z, NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+	return z, pc.LocalAddr().String()
+}
+
+func TestDNSSECResolverADFlag(t *testing.T) {
+	_, addr := serveSignedZone(t)
+	r := NewDNSSECResolver(addr)
+	if _, err := spf.New("test.com", r); err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	TestTable := []struct {
+		domain string
+		rtype  string
+		status SecurityStatus
+	}{
+		{"test.com", "TXT", Secure},
+		{"test.com", "A", Secure},
+		{"unsigned.test.com", "TXT", Insecure},
+	}
+	for _, testCase := range TestTable {
+		if s := r.Status(testCase.domain, testCase.rtype); s != testCase.status {
+			t.Errorf("wrong status for %s %s wanted %s got %s",
+				testCase.domain, testCase.rtype, testCase.status, s)
+		}
+	}
+	if ttl, ok := r.TTL("test.com", "TXT"); !ok || ttl != 300 {
+		t.Errorf("wrong TTL wanted 300 got %d", ttl)
+	}
+}
+
+func TestDNSSECResolverValidation(t *testing.T) {
+	z, addr := serveSignedZone(t)
+	r := NewDNSSECResolver(addr, z.key.ToDS(dns.SHA256))
+	if _, err := spf.New("test.com", r); err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	if _, err := r.TextRecord("bogus.test.com"); err != nil {
+		t.Fatalf("lookup should not have failed but got %q", err)
+	}
+	TestTable := []struct {
+		domain string
+		rtype  string
+		status SecurityStatus
+	}{
+		{"test.com", "TXT", Secure},
+		{"test.com", "A", Secure},
+		{"unsigned.test.com", "TXT", Insecure},
+		{"test.com", "AAAA", Insecure},
+		{"bogus.test.com", "TXT", Bogus},
+	}
+	for _, testCase := range TestTable {
+		if s := r.Status(testCase.domain, testCase.rtype); s != testCase.status {
+			t.Errorf("wrong status for %s %s wanted %s got %s",
+				testCase.domain, testCase.rtype, testCase.status, s)
+		}
+	}
+
+	wrongAnchor := z.key.ToDS(dns.SHA256)
+	digest := []byte(wrongAnchor.Digest)
+	if digest[0] == '0' {
+		digest[0] = '1'
+	} else {
+		digest[0] = '0'
+	}
+	wrongAnchor.Digest = string(digest)
+	r = NewDNSSECResolver(addr, wrongAnchor)
+	if _, err := r.TextRecord("test.com"); err != nil {
+		t.Fatalf("lookup should not have failed but got %q", err)
+	}
+	if s := r.Status("test.com", "TXT"); s != Bogus {
+		t.Errorf("answer with untrusted key should be bogus got %s", s)
+	}
+}
+
+func TestDNSSECResolverTrace(t *testing.T) {
+	z, addr := serveSignedZone(t)
+	r := NewDNSSECResolver(addr, z.key.ToDS(dns.SHA256))
+	TestTable := []struct {
+		ip      string
+		verdict spf.Qualifier
+		hops    []SecuredHop
+	}{
+		{"192.168.1.1", spf.Pass, []SecuredHop{
+			{Term: "", Chain: true, Domain: "test.com", Type: "TXT", Status: Secure},
+			{Term: "a", Chain: true, Domain: "test.com", Type: "A", Status: Secure},
+			{Term: "a", Chain: true, Domain: "test.com", Type: "AAAA", Status: Insecure},
+			{Domain: "unsigned.test.com", Type: "TXT", Status: Insecure},
+		}},
+		{"10.1.1.1", spf.Pass, []SecuredHop{
+			{Term: "", Chain: true, Domain: "test.com", Type: "TXT", Status: Secure},
+			{Domain: "test.com", Type: "A", Status: Secure},
+			{Domain: "test.com", Type: "AAAA", Status: Insecure},
+			{Term: "include:unsigned.test.com", Chain: true, Domain: "unsigned.test.com", Type: "TXT", Status: Insecure},
+		}},
+		{"172.16.1.1", spf.Fail, []SecuredHop{
+			{Term: "", Chain: true, Domain: "test.com", Type: "TXT", Status: Secure},
+			{Domain: "test.com", Type: "A", Status: Secure},
+			{Domain: "test.com", Type: "AAAA", Status: Insecure},
+			{Domain: "unsigned.test.com", Type: "TXT", Status: Insecure},
+		}},
+	}
+	for _, testCase := range TestTable {
+		q, hops, err := r.Trace("test.com", net.ParseIP(testCase.ip))
+		if err != nil {
+			t.Fatalf("trace should not have failed but got %q", err)
+		}
+		if q != testCase.verdict || len(hops) != len(testCase.hops) {
+			t.Fatalf("wrong trace for %s got %v %+v", testCase.ip, q, hops)
+		}
+		for i, want := range testCase.hops {
+			got := hops[i]
+			got.Reason = ""
+			if got != want {
+				t.Errorf("wrong hop %d for %s wanted %+v got %+v", i, testCase.ip, want, got)
+			}
+		}
+	}
+	if n := len(r.Lookups()); n != 4*len(TestTable) {
+		t.Errorf("every traced lookup should also be recorded by the resolver got %d", n)
+	}
+
+	_, hops, err := r.Trace("servfail.test.com", net.ParseIP("10.1.1.1"))
+	if !errors.Is(err, spf.TemporaryError) || len(hops) != 1 || hops[0].Domain != "servfail.test.com" {
+		t.Errorf("failed trace should return the lookups made got %v %+v", err, hops)
+	}
+}
+
+func TestDNSSECResolverServfail(t *testing.T) {
+	_, addr := serveSignedZone(t)
+	r := NewDNSSECResolver(addr)
+	_, err := r.TextRecord("servfail.test.com")
+	var dnsErr *net.DNSError
+	if !errors.Is(err, spf.TemporaryError) || !errors.As(err, &dnsErr) || !dnsErr.IsTemporary {
+		t.Errorf("SERVFAIL should be a temporary error got %v", err)
+	}
+	if _, err := spf.New("servfail.test.com", r); !errors.Is(err, spf.TemporaryError) {
+		t.Errorf("SERVFAIL should make the evaluation a temperror got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/VenditoKft/spfing/spf/dnsclient
+
+go 1.24.0
+
+require (
+	github.com/VenditoKft/spfing/spf v0.0.0
+	github.com/miekg/dns v1.1.72
+)
+
+require (
+	golang.org/x/mod v0.31.0 // indirect
+	golang.org/x/net v0.48.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/tools v0.40.0 // indirect
+)
+
+replace github.com/VenditoKft/spfing/spf => ../
@@ -0,0 +1,189 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	TooManyLookups = errors.New("dns lookup limit exceeded")
+	NotInRecord    = errors.New("term not in record")
+)
+
+var layoutRegex = regexp.MustCompile(`\S+|\s+`)
+
+// Editor changes a parsed record term by term. Everything it does not touch,
+// term order, case and whitespace included, stays as published.
+type Editor struct {
+	spf     *SPF
+	tokens  []string
+	costs   map[string]int
+	lookups int
+}
+
+// Edit returns an editor for the record. The lookups of the record and of
+// terms inserted later are counted against MaxLookups.
+func (spf *SPF) Edit() *Editor {
+	e := &Editor{
+		spf:     spf,
+		tokens:  layoutRegex.FindAllString(spf.Record, -1),
+		costs:   make(map[string]int),
+		lookups: lookupCount(spf),
+	}
+	for _, t := range recordTerms(spf) {
+		e.costs[editKey(t.term)] = termLookups(t)
+	}
+	return e
+}
+
+// Insert adds term to the record. Mechanisms go right before the all
+// mechanism, or before the modifiers when there is no all; modifiers go to
+// the end. An include or redirect is resolved to count the lookups it adds.
+func (e *Editor) Insert(term string) error {
+	if typos := FindTypos("v=spf1 " + term); len(typos) > 0 {
+		return fmt.Errorf("%w - %s: %s", WrongFormat, term, typos[0].Problem)
+	}
+	if e.find(term) >= 0 {
+		return nil
+	}
+	if isRedirectModifier(term) && e.findName("redirect") >= 0 {
+		return fmt.Errorf("%w - record already has a redirect", WrongFormat)
+	}
+	var m Mechanism
+	var err error
+	switch {
+	case IsIncludeMechanism(term):
+		m, err = NewInclude(term, e.spf.r, e.spf.childOpts(nil)...)
+	case isRedirectModifier(term):
+		m, err = NewRedirect(term, e.spf.r, e.spf.childOpts(nil)...)
+	}
+	if err != nil {
+		return err
+	}
+	return e.insert(term, m)
+}
+
+// insert adds a checked term, m is its resolved include or redirect and nil
+// for other terms.
+func (e *Editor) insert(term string, m Mechanism) error {
+	cost := ownLookups(term)
+	if m != nil {
+		cost = termLookups(recordTerm{term: term, m: m})
+	}
+	if e.lookups+cost > MaxLookups {
+		return fmt.Errorf("%w - %s needs %d lookups, the record already needs %d", TooManyLookups, term, cost, e.lookups)
+	}
+
+	at := len(e.tokens)
+	if at > 0 && strings.TrimSpace(e.tokens[at-1]) == "" {
+		at--
+	}
+	if !strings.Contains(term, "=") {
+		if i := e.findName("all"); i >= 0 {
+			at = i
+		} else if i := e.firstModifier(); i >= 0 {
+			at = i
+		}
+	}
+	if at < len(e.tokens) && strings.TrimSpace(e.tokens[at]) != "" {
+		e.tokens = append(e.tokens[:at], append([]string{term, " "}, e.tokens[at:]...)...)
+	} else {
+		e.tokens = append(e.tokens[:at], append([]string{" ", term}, e.tokens[at:]...)...)
+	}
+	e.costs[editKey(term)] = cost
+	e.lookups += cost
+	return nil
+}
+
+// Remove deletes term, matched without regard to case or a + qualifier,
+// together with the whitespace in front of it. The version term cannot be
+// removed.
+func (e *Editor) Remove(term string) error {
+	if editKey(term) == "v=spf1" {
+		return fmt.Errorf("%w - the version term cannot be removed", WrongFormat)
+	}
+	i := e.find(term)
+	if i < 0 {
+		return fmt.Errorf("%w - %s", NotInRecord, term)
+	}
+	e.lookups -= e.costs[editKey(e.tokens[i])]
+	switch {
+	case i > 0:
+		e.tokens = append(e.tokens[:i-1], e.tokens[i+1:]...)
+	case i+1 < len(e.tokens):
+		e.tokens = e.tokens[i+2:]
+	default:
+		e.tokens = nil
+	}
+	return nil
+}
+
+// SetAllQualifier changes the qualifier of the all mechanism, adding one in
+// front of the modifiers when the record has none.
+func (e *Editor) SetAllQualifier(q Qualifier) error {
+	symbol := qualifierPrefix(q)
+	if q == Pass {
+		symbol = "+"
+	}
+	i := e.findName("all")
+	if i < 0 {
+		return e.Insert(symbol + "all")
+	}
+	e.tokens[i] = symbol + strings.TrimLeft(e.tokens[i], "+-~?")
+	return nil
+}
+
+// Lookups returns the number of lookups the edited record needs.
+func (e *Editor) Lookups() int {
+	return e.lookups
+}
+
+func (e *Editor) String() string {
+	return strings.Join(e.tokens, "")
+}
+
+func (e *Editor) find(term string) int {
+	for i, v := range e.tokens {
+		if editKey(v) == editKey(term) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Editor) findName(name string) int {
+	for i, v := range e.tokens {
+		if strings.TrimSpace(v) != "" && termName(v) == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Editor) firstModifier() int {
+	for i, v := range e.tokens {
+		if strings.Contains(v, "=") && !strings.EqualFold(v, "v=spf1") {
+			return i
+		}
+	}
+	return -1
+}
+
+// editKey is the form terms are compared in.
+func editKey(term string) string {
+	return strings.ToLower(strings.TrimPrefix(term, "+"))
+}
+
+// termLookups returns the lookups a term costs including the records it
+// reaches.
+func termLookups(t recordTerm) int {
+	switch v := t.m.(type) {
+	case Include:
+		return 1 + lookupCount(&v.spf)
+	case Redirect:
+		return 1 + lookupCount(&v.spf)
+	}
+	return ownLookups(t.term)
+}
@@ -0,0 +1,141 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func editorTestResolver() MockResolver {
+	return MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":      []string{"v=spf1  MX ip4:10.0.0.0/8   include:_spf.test.com ~all "},
+			"redirect.com":  []string{"v=spf1 a redirect=test.com exp=explain.redirect.com"},
+			"_spf.test.com": []string{"v=spf1 a -all"},
+			"big.test.com":  []string{"v=spf1 a mx a:x.test.com mx:y.test.com a:z.test.com include:_spf.test.com -all"},
+			"new.test.com":  []string{"v=spf1 ip4:192.0.2.0/24 -all"},
+		},
+		mxDomains: mxDomainPair{
+			"big.test.com": {{Host: "x.test.com"}},
+			"y.test.com":   {{Host: "z.test.com"}},
+		},
+		aDomains: aDomainPair{
+			"big.test.com": {net.ParseIP("192.0.2.1")},
+			"x.test.com":   {net.ParseIP("192.0.2.2")},
+			"z.test.com":   {net.ParseIP("192.0.2.3")},
+		},
+	}
+}
+
+func TestEditor(t *testing.T) {
+	TestTable := []struct {
+		testCase string
+		domain   string
+		edit     func(e *Editor) error
+		want     string
+		lookups  int
+	}{
+		{
+			testCase: "insert before all",
+			domain:   "test.com",
+			edit:     func(e *Editor) error { return e.Insert("include:new.test.com") },
+			want:     "v=spf1  MX ip4:10.0.0.0/8   include:_spf.test.com include:new.test.com ~all ",
+			lookups:  4,
+		},
+		{
+			testCase: "insert before modifiers",
+			domain:   "redirect.com",
+			edit:     func(e *Editor) error { return e.Insert("ip6:2001:db8::/32") },
+			want:     "v=spf1 a ip6:2001:db8::/32 redirect=test.com exp=explain.redirect.com",
+			lookups:  5,
+		},
+		{
+			testCase: "remove",
+			domain:   "test.com",
+			edit:     func(e *Editor) error { return e.Remove("+IP4:10.0.0.0/8") },
+			want:     "v=spf1  MX   include:_spf.test.com ~all ",
+			lookups:  3,
+		},
+		{
+			testCase: "remove include",
+			domain:   "test.com",
+			edit:     func(e *Editor) error { return e.Remove("include:_spf.test.com") },
+			want:     "v=spf1  MX ip4:10.0.0.0/8 ~all ",
+			lookups:  1,
+		},
+		{
+			testCase: "change all",
+			domain:   "test.com",
+			edit:     func(e *Editor) error { return e.SetAllQualifier(Fail) },
+			want:     "v=spf1  MX ip4:10.0.0.0/8   include:_spf.test.com -all ",
+			lookups:  3,
+		},
+		{
+			testCase: "add all",
+			domain:   "redirect.com",
+			edit:     func(e *Editor) error { return e.SetAllQualifier(Softfail) },
+			want:     "v=spf1 a ~all redirect=test.com exp=explain.redirect.com",
+			lookups:  5,
+		},
+		{
+			testCase: "insert unknown modifier",
+			domain:   "redirect.com",
+			edit:     func(e *Editor) error { return e.Insert("foo=bar") },
+			want:     "v=spf1 a redirect=test.com exp=explain.redirect.com foo=bar",
+			lookups:  5,
+		},
+		{
+			testCase: "insert existing term",
+			domain:   "test.com",
+			edit:     func(e *Editor) error { return e.Insert("mx") },
+			want:     "v=spf1  MX ip4:10.0.0.0/8   include:_spf.test.com ~all ",
+			lookups:  3,
+		},
+	}
+
+	res := editorTestResolver()
+	for _, test := range TestTable {
+		spf, err := New(test.domain, res)
+		if err != nil {
+			t.Fatalf("%s: creating SPF should not have failed but got %q", test.testCase, err)
+		}
+		e := spf.Edit()
+		if err := test.edit(e); err != nil {
+			t.Errorf("%s: edit should not have failed but got %q", test.testCase, err)
+		}
+		if got := e.String(); got != test.want {
+			t.Errorf("%s: wrong record wanted\n%q\ngot\n%q", test.testCase, test.want, got)
+		}
+		if got := e.Lookups(); got != test.lookups {
+			t.Errorf("%s: wanted %d lookups got %d", test.testCase, test.lookups, got)
+		}
+	}
+}
+
+func TestEditorErrors(t *testing.T) {
+	res := editorTestResolver()
+	spf, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	TestTable := []struct {
+		testCase string
+		edit     func(e *Editor) error
+		err      error
+	}{
+		{"too many lookups", func(e *Editor) error { return e.Insert("include:big.test.com") }, TooManyLookups},
+		{"missing term", func(e *Editor) error { return e.Remove("ip4:192.0.2.1") }, NotInRecord},
+		{"malformed term", func(e *Editor) error { return e.Insert("inlcude:new.test.com") }, WrongFormat},
+		{"remove version", func(e *Editor) error { return e.Remove("v=spf1") }, WrongFormat},
+		{"remove version in other case", func(e *Editor) error { return e.Remove("V=SPF1") }, WrongFormat},
+	}
+	for _, test := range TestTable {
+		e := spf.Edit()
+		if err := test.edit(e); !errors.Is(err, test.err) {
+			t.Errorf("%s: wanted error %q got %v", test.testCase, test.err, err)
+		}
+		if e.String() != spf.Record {
+			t.Errorf("%s: failed edit should not have changed the record", test.testCase)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package spf
+
+import (
+	"net/netip"
+	"reflect"
+)
+
+// RangeVerdict is the result for a range of addresses and the chain of
+// terms leading to the deciding term, empty when no term matched.
+type RangeVerdict struct {
+	From     netip.Addr
+	To       netip.Addr
+	Prefixes []netip.Prefix
+	Result   Qualifier
+	Chain    []string
+}
+
+// EvaluateRange splits p into the non-overlapping ranges that get the same
+// result through the same chain and returns them in address order. The
+// ranges follow the edges of the networks of the tree, so evaluating a large
+// prefix costs one evaluation per edge inside it, not one per address. An
+// evaluation error is returned together with the ranges before it.
+func (spf *SPF) EvaluateRange(p netip.Prefix) (verdicts []RangeVerdict, errRtn error) {
+	p = p.Masked()
+	first, last := p.Addr(), lastAddr(p)
+	for _, r := range elementaryIntervals(append(treePrefixes(spf), p)) {
+		if r.To.Less(first) || last.Less(r.From) {
+			continue
+		}
+		q, chain, err := verdictAt(spf, r.From)
+		if err != nil {
+			errRtn = err
+			break
+		}
+		if n := len(verdicts); n > 0 {
+			v := &verdicts[n-1]
+			if v.To.Next() == r.From && v.Result == q && reflect.DeepEqual(v.Chain, chain) {
+				v.To = r.To
+				continue
+			}
+		}
+		verdicts = append(verdicts, RangeVerdict{From: r.From, To: r.To, Result: q, Chain: chain})
+	}
+	for i := range verdicts {
+		verdicts[i].Prefixes = addrInterval{From: verdicts[i].From, To: verdicts[i].To}.Prefixes()
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateRange(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":      []string{"v=spf1 ip4:203.0.113.0/24 include:_spf.test.com ~ip4:203.0.114.0/23 -all"},
+			"_spf.test.com": []string{"v=spf1 ip4:203.0.114.128/25 -all"},
+		},
+	}
+	spf, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	verdict := func(from string, to string, q Qualifier, prefixes []string, chain ...string) RangeVerdict {
+		v := RangeVerdict{From: netip.MustParseAddr(from), To: netip.MustParseAddr(to), Result: q, Chain: chain}
+		for _, p := range prefixes {
+			v.Prefixes = append(v.Prefixes, netip.MustParsePrefix(p))
+		}
+		return v
+	}
+	want := []RangeVerdict{
+		verdict("203.0.112.0", "203.0.112.255", Fail, []string{"203.0.112.0/24"}, "-all"),
+		verdict("203.0.113.0", "203.0.113.255", Pass, []string{"203.0.113.0/24"}, "ip4:203.0.113.0/24"),
+		verdict("203.0.114.0", "203.0.114.127", Softfail, []string{"203.0.114.0/25"}, "~ip4:203.0.114.0/23"),
+		verdict("203.0.114.128", "203.0.114.255", Pass, []string{"203.0.114.128/25"}, "include:_spf.test.com", "ip4:203.0.114.128/25"),
+		verdict("203.0.115.0", "203.0.115.255", Softfail, []string{"203.0.115.0/24"}, "~ip4:203.0.114.0/23"),
+	}
+	got, err := spf.EvaluateRange(netip.MustParsePrefix("203.0.113.77/22"))
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong verdicts wanted\n%+v\ngot\n%+v %v", want, got, err)
+	}
+
+	got, err = spf.EvaluateRange(netip.MustParsePrefix("2001:db8::/64"))
+	if err != nil || len(got) != 1 || got[0].Result != Fail || got[0].Prefixes[0] != netip.MustParsePrefix("2001:db8::/64") {
+		t.Errorf("wrong IPv6 verdicts got %+v", got)
+	}
+}
+
+// failingMechanism cannot evaluate IPv6 addresses.
+type failingMechanism struct{}
+
+func (failingMechanism) Match(ip net.IP) ([]string, error) {
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("%w - lookup for %s failed", DNSResolutionError, ip)
+	}
+	return nil, nil
+}
+
+func TestRangeErrors(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{"test.com": []string{"v=spf1 ip4:203.0.113.0/24 -all"}},
+	}
+	good, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	broken, _ := New("test.com", res)
+	broken.Mechanisms = append([]Mechanism{failingMechanism{}}, broken.Mechanisms...)
+
+	if got, err := broken.EvaluateRange(netip.MustParsePrefix("203.0.113.0/24")); err != nil || len(got) != 1 {
+		t.Errorf("IPv4 range should evaluate got %+v %v", got, err)
+	}
+	if _, err := broken.EvaluateRange(netip.MustParsePrefix("2001:db8::/64")); !errors.Is(err, DNSResolutionError) {
+		t.Errorf("evaluation error should be returned got %v", err)
+	}
+	if _, err := Compare(&good, &broken); !errors.Is(err, DNSResolutionError) || !strings.Contains(err.Error(), "second record") {
+		t.Errorf("comparison should return the error of the second record got %v", err)
+	}
+	if _, err := broken.Authorized(); !errors.Is(err, DNSResolutionError) {
+		t.Errorf("authorized addresses should return the evaluation error got %v", err)
+	}
+	if _, err := broken.CloudExposure(nil); !errors.Is(err, DNSResolutionError) {
+		t.Errorf("cloud exposure should return the evaluation error got %v", err)
+	}
+}
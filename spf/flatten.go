@@ -0,0 +1,193 @@
+package spf
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// Flattened is a record with its include, a, mx and redirect terms replaced
+// by the networks they resolved to. Kept holds the terms that had to stay
+// as they are. Lookups is the number of DNS lookups the flattened record
+// still needs, Original the number the record needed before.
+type Flattened struct {
+	Record   string
+	Kept     []string
+	Lookups  int
+	Original int
+}
+
+// Flatten builds the record of domain and flattens it, see SPF.Flatten.
+func Flatten(domain string, res resolver, opts ...Option) (Flattened, error) {
+	spf, err := New(domain, res, opts...)
+	if err != nil {
+		return Flattened{}, err
+	}
+	return spf.Flatten(), nil
+}
+
+// Flatten returns an equivalent record that needs as few lookups as
+// possible. a, mx and ip terms become ip4 and ip6 terms with their
+// qualifier. An include becomes the networks of the included tree under the
+// include's qualifier, but only when every mechanism it reaches passes:
+// a non-pass mechanism inside an include changes which addresses it
+// matches and is not expressible as a list of networks. Such includes, and
+// ptr, exists and macro terms, are kept as they are. A redirect is replaced
+// by the flattened terms of its target. Networks that an earlier term
+// already covers are dropped and neighbouring terms with the same qualifier
+// are merged into the fewest prefixes.
+func (spf *SPF) Flatten() Flattened {
+	f := flattener{}
+	f.record(spf, true)
+	f.flush()
+	terms := append([]string{"v=spf1"}, f.terms...)
+	terms = append(terms, f.modifiers...)
+	record := strings.Join(terms, " ")
+	return Flattened{
+		Record:   record,
+		Kept:     f.kept,
+		Lookups:  ownLookups(record) + f.lookups,
+		Original: lookupCount(spf),
+	}
+}
+
+type flattener struct {
+	terms     []string
+	modifiers []string
+	kept      []string
+	lookups   int
+	covered   []netip.Prefix
+	pending   []netip.Prefix
+	qualifier Qualifier
+	done      bool
+}
+
+// record appends the flattened terms of spf as evaluated at the top level,
+// following its redirect after every mechanism when it has no all, wherever
+// the redirect is written. Only the exp of the top record is kept.
+func (f *flattener) record(spf *SPF, top bool) {
+	var redirect *SPF
+	for _, t := range recordTerms(spf) {
+		if f.done && !strings.Contains(t.term, "=") {
+			continue
+		}
+		switch v := t.m.(type) {
+		case A, MX, IP:
+			if !strings.Contains(t.term, "%") {
+				f.add(qualifierOf(v), networkPrefixes(v))
+				continue
+			}
+		case Include:
+			if prefixes, ok := passingPrefixes(&v.spf); ok {
+				f.add(v.Qualifier, prefixes)
+				continue
+			}
+			f.lookups += lookupCount(&v.spf)
+		case All:
+			f.keep(t.term)
+			f.done = true
+			continue
+		case Redirect:
+			redirect = &v.spf
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(t.term), "exp=") {
+			if top {
+				f.modifiers = append(f.modifiers, t.term)
+			}
+			continue
+		}
+		if isRedirectModifier(t.term) {
+			continue
+		}
+		f.keep(t.term)
+		f.kept = append(f.kept, t.term)
+	}
+	if redirect != nil && !spf.hasAll() {
+		f.record(redirect, false)
+	}
+}
+
+// add queues the prefixes not covered yet under qualifier q.
+func (f *flattener) add(q Qualifier, prefixes []netip.Prefix) {
+	if q != f.qualifier && len(f.pending) > 0 {
+		f.flush()
+	}
+	f.qualifier = q
+	for _, p := range prefixes {
+		if !coveredBy(f.covered, p) {
+			f.pending = append(f.pending, p)
+		}
+	}
+}
+
+func (f *flattener) keep(term string) {
+	f.flush()
+	f.terms = append(f.terms, term)
+}
+
+func (f *flattener) flush() {
+	for _, p := range mergePrefixes(f.pending) {
+		f.terms = append(f.terms, prefixTerm(f.qualifier, p))
+		f.covered = append(f.covered, p)
+	}
+	f.pending = nil
+}
+
+func coveredBy(covered []netip.Prefix, p netip.Prefix) bool {
+	for _, c := range covered {
+		if c.Bits() <= p.Bits() && c.Contains(p.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+func networkPrefixes(m Mechanism) (prefixes []netip.Prefix) {
+	for _, n := range networksOf(m) {
+		prefixes = append(prefixes, prefixOf(n))
+	}
+	return
+}
+
+// passingPrefixes returns the networks an included record matches when all
+// its mechanisms pass; ok is false when the record holds anything else than
+// pass mechanisms and a trailing non-pass all.
+func passingPrefixes(spf *SPF) (prefixes []netip.Prefix, ok bool) {
+	sawAll := false
+	for _, t := range recordTerms(spf) {
+		if strings.Contains(t.term, "%") || (sawAll && !strings.Contains(t.term, "=")) {
+			return nil, false
+		}
+		switch v := t.m.(type) {
+		case A, MX, IP:
+			if qualifierOf(v) != Pass {
+				return nil, false
+			}
+			prefixes = append(prefixes, networkPrefixes(v)...)
+		case Include:
+			inner, ok := passingPrefixes(&v.spf)
+			if !ok || v.Qualifier != Pass {
+				return nil, false
+			}
+			prefixes = append(prefixes, inner...)
+		case All:
+			if v.Qualifier == Pass {
+				return nil, false
+			}
+			sawAll = true
+		case Redirect:
+			if !spf.hasAll() {
+				inner, ok := passingPrefixes(&v.spf)
+				if !ok {
+					return nil, false
+				}
+				prefixes = append(prefixes, inner...)
+			}
+		default:
+			if !strings.HasPrefix(strings.ToLower(t.term), "exp=") {
+				return nil, false
+			}
+		}
+	}
+	return prefixes, true
+}
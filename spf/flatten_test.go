@@ -0,0 +1,129 @@
+package spf
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func flattenTestResolver() MockResolver {
+	return MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com": []string{"v=spf1 a mx include:_spf.test.com ip4:192.0.2.128/25 include:mixed.test.com " +
+				"exists:%{i}.x.test.com ~include:soft.test.com -all exp=explain.test.com"},
+			"_spf.test.com":     []string{"v=spf1 ip4:192.0.2.0/25 ip4:10.0.0.0/8 ip6:2001:db8::/33 ip6:2001:db8:8000::/33 ~all"},
+			"mixed.test.com":    []string{"v=spf1 -ip4:10.9.9.9 ip4:198.51.100.0/24 -all"},
+			"soft.test.com":     []string{"v=spf1 ip4:203.0.113.0/24 -all"},
+			"redirect.com":      []string{"v=spf1 ip4:10.0.0.1 redirect=_spf.redirect.com"},
+			"_spf.redirect.com": []string{"v=spf1 ip4:10.0.0.0/24 a:mail.test.com ?all"},
+			"both.com":          []string{"v=spf1 ip4:10.0.0.1 -all redirect=_spf.redirect.com"},
+			"first.com":         []string{"v=spf1 redirect=_spf.redirect.com ip4:192.0.2.1 -ip4:10.0.0.77"},
+		},
+		mxDomains: mxDomainPair{"test.com": {{Host: "mail.test.com", Pref: 10}}},
+		aDomains: aDomainPair{
+			"test.com":      {net.ParseIP("192.0.2.1")},
+			"mail.test.com": {net.ParseIP("10.1.2.3")},
+		},
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	TestTable := []struct {
+		testCase string
+		domain   string
+		want     Flattened
+	}{
+		{
+			testCase: "includes and address mechanisms",
+			domain:   "test.com",
+			want: Flattened{
+				Record: "v=spf1 ip4:10.0.0.0/8 ip4:192.0.2.0/24 ip6:2001:db8::/32 include:mixed.test.com " +
+					"exists:%{i}.x.test.com ~ip4:203.0.113.0/24 -all exp=explain.test.com",
+				Kept:     []string{"include:mixed.test.com", "exists:%{i}.x.test.com"},
+				Lookups:  2,
+				Original: 6,
+			},
+		},
+		{
+			testCase: "redirect",
+			domain:   "redirect.com",
+			want: Flattened{
+				Record:   "v=spf1 ip4:10.0.0.0/24 ip4:10.1.2.3 ?all",
+				Lookups:  0,
+				Original: 2,
+			},
+		},
+		{
+			testCase: "redirect ignored next to all",
+			domain:   "both.com",
+			want: Flattened{
+				Record:   "v=spf1 ip4:10.0.0.1 -all",
+				Lookups:  0,
+				Original: 2,
+			},
+		},
+		{
+			testCase: "redirect before mechanisms",
+			domain:   "first.com",
+			want: Flattened{
+				Record:   "v=spf1 ip4:192.0.2.1 -ip4:10.0.0.77 ip4:10.0.0.0/24 ip4:10.1.2.3 ?all",
+				Lookups:  0,
+				Original: 2,
+			},
+		},
+	}
+
+	res := flattenTestResolver()
+	for _, test := range TestTable {
+		got, err := Flatten(test.domain, res)
+		if err != nil {
+			t.Fatalf("%s: flattening should not have failed but got %q", test.testCase, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: wrong result wanted\n%+v\ngot\n%+v", test.testCase, test.want, got)
+		}
+	}
+}
+
+func TestFlattenKeepsVerdicts(t *testing.T) {
+	res := flattenTestResolver()
+	for _, domain := range []string{"test.com", "redirect.com", "both.com", "first.com"} {
+		original, err := New(domain, res)
+		if err != nil {
+			t.Fatalf("creating SPF should not have failed but got %q", err)
+		}
+		res.txtDomains["flat."+domain] = []string{original.Flatten().Record}
+		flat, err := New("flat."+domain, res)
+		if err != nil {
+			t.Fatalf("creating flattened SPF should not have failed but got %q", err)
+		}
+		for _, ip := range []string{"192.0.2.1", "192.0.2.200", "10.1.2.3", "10.9.9.9", "10.0.0.77",
+			"198.51.100.1", "203.0.113.9", "2001:db8:ffff::1", "8.8.8.8"} {
+			wantQ, _, _ := original.Verdict(net.ParseIP(ip))
+			gotQ, _, _ := flat.Verdict(net.ParseIP(ip))
+			if wantQ != gotQ {
+				t.Errorf("%s %s: flattened verdict %s differs from %s", domain, ip, gotQ, wantQ)
+			}
+		}
+	}
+}
+
+func TestMergePrefixes(t *testing.T) {
+	in := []netip.Prefix{
+		netip.MustParsePrefix("10.0.1.0/24"),
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("10.0.0.5/32"),
+		netip.MustParsePrefix("10.0.3.0/24"),
+		netip.MustParsePrefix("2001:db8:1::/48"),
+		netip.MustParsePrefix("2001:db8::/48"),
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/23"),
+		netip.MustParsePrefix("10.0.3.0/24"),
+		netip.MustParsePrefix("2001:db8::/47"),
+	}
+	if got := mergePrefixes(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong merge wanted %v got %v", want, got)
+	}
+}
@@ -0,0 +1,159 @@
+package spf
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HorizonView is what evaluating a domain through one resolver produced.
+// Records maps every domain of the include and redirect tree to its record,
+// Networks maps every network of the tree to the term chain providing it.
+type HorizonView struct {
+	Name     string
+	Record   string
+	Records  map[string]string
+	Networks map[string]string
+	Verdict  Qualifier
+	Match    []string
+	Error    string
+}
+
+// HorizonDifference is one point where the views disagree. Kind is "record",
+// "network", "verdict" or "error" and Subject the domain or network the
+// difference is about. Values holds the value per view name, empty when a
+// view has nothing for Subject.
+type HorizonDifference struct {
+	Kind    string
+	Subject string
+	Values  map[string]string
+}
+
+type HorizonReport struct {
+	Domain      string
+	IP          net.IP
+	Views       []HorizonView
+	Differences []HorizonDifference
+}
+
+// CompareResolvers evaluates domain and ip through every resolver at once
+// and reports where the records, networks and verdicts they saw differ. It
+// is meant to spot split-horizon DNS serving different SPF data internally
+// and externally.
+func CompareResolvers(domain string, ip net.IP, resolvers map[string]Resolver) HorizonReport {
+	report := HorizonReport{Domain: domain, IP: ip}
+	names := make([]string, 0, len(resolvers))
+	for name := range resolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	report.Views = make([]HorizonView, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			report.Views[i] = horizonView(name, domain, ip, resolvers[name])
+		}(i, name)
+	}
+	wg.Wait()
+	report.Differences = horizonDifferences(report.Views)
+	return report
+}
+
+func horizonView(name string, domain string, ip net.IP, res resolver) HorizonView {
+	v := HorizonView{
+		Name:     name,
+		Records:  make(map[string]string),
+		Networks: make(map[string]string),
+		Verdict:  Neutral,
+	}
+	spf, err := New(domain, res)
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+	v.Record = spf.Record
+	v.Records[canonicalName(domain)] = spf.Record
+	spf.walk(func(path []string, m Mechanism) {
+		switch t := m.(type) {
+		case Include:
+			v.Records[canonicalName(t.Domain)] = t.spf.Record
+		case Redirect:
+			v.Records[canonicalName(t.Domain)] = t.spf.Record
+		}
+		for _, n := range networksOf(m) {
+			if _, ok := v.Networks[n.String()]; !ok {
+				v.Networks[n.String()] = strings.Join(append(path[:len(path):len(path)], termOf(m)), " > ")
+			}
+		}
+	})
+	v.Verdict, v.Match, err = spf.Verdict(ip)
+	if err != nil {
+		v.Error = err.Error()
+	}
+	return v
+}
+
+// termOf returns the record term a mechanism was parsed from.
+func termOf(m Mechanism) string {
+	switch v := m.(type) {
+	case A:
+		return v.Record
+	case MX:
+		return v.Record
+	case IP:
+		return v.Record
+	case Include:
+		return v.Record
+	case All:
+		return v.Record
+	case Redirect:
+		return v.Record
+	}
+	return ""
+}
+
+func horizonDifferences(views []HorizonView) (diffs []HorizonDifference) {
+	collect := func(kind string, subject string, value func(v HorizonView) string) {
+		values := make(map[string]string, len(views))
+		distinct := make(map[string]bool)
+		for _, v := range views {
+			values[v.Name] = value(v)
+			distinct[values[v.Name]] = true
+		}
+		if len(distinct) > 1 {
+			diffs = append(diffs, HorizonDifference{Kind: kind, Subject: subject, Values: values})
+		}
+	}
+	collect("error", "", func(v HorizonView) string { return v.Error })
+	for _, d := range unionKeys(views, func(v HorizonView) map[string]string { return v.Records }) {
+		collect("record", d, func(v HorizonView) string { return v.Records[d] })
+	}
+	for _, n := range unionKeys(views, func(v HorizonView) map[string]string { return v.Networks }) {
+		collect("network", n, func(v HorizonView) string { return v.Networks[n] })
+	}
+	collect("verdict", "", func(v HorizonView) string {
+		if v.Error != "" {
+			return ""
+		}
+		return v.Verdict.String() + " " + strings.Join(v.Match, " > ")
+	})
+	return
+}
+
+func unionKeys(views []HorizonView, field func(v HorizonView) map[string]string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, v := range views {
+		for k := range field(v) {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
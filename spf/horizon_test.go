@@ -0,0 +1,50 @@
+package spf
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCompareResolvers(t *testing.T) {
+	internal := NewOverlayResolver(loadTestZone(t))
+	internal.SetTXT("_spf.test.com", "v=spf1 ip4:172.16.0.0/16 ip4:10.9.0.0/16 -all")
+	report := CompareResolvers("test.com", net.ParseIP("10.9.1.1"), map[string]Resolver{
+		"external": loadTestZone(t),
+		"internal": internal,
+	})
+	if len(report.Views) != 2 || report.Views[0].Name != "external" {
+		t.Fatalf("views were not sorted by name got %+v", report.Views)
+	}
+	if report.Views[0].Verdict != Fail || report.Views[1].Verdict != Pass {
+		t.Errorf("wrong verdicts got %s and %s", report.Views[0].Verdict, report.Views[1].Verdict)
+	}
+	want := map[string]HorizonDifference{
+		"record _spf.test.com": {Values: map[string]string{
+			"external": "v=spf1 ip4:172.16.0.0/16 -all",
+			"internal": "v=spf1 ip4:172.16.0.0/16 ip4:10.9.0.0/16 -all",
+		}},
+		"network 10.9.0.0/16": {Values: map[string]string{
+			"external": "",
+			"internal": "include:_spf.test.com > ip4:10.9.0.0/16",
+		}},
+		"verdict ": {Values: map[string]string{
+			"external": "fail -all",
+			"internal": "pass include:_spf.test.com > ip4:10.9.0.0/16",
+		}},
+	}
+	if len(report.Differences) != len(want) {
+		t.Fatalf("expected %d differences got %+v", len(want), report.Differences)
+	}
+	for _, d := range report.Differences {
+		w, ok := want[d.Kind+" "+d.Subject]
+		if !ok {
+			t.Errorf("unexpected difference %+v", d)
+			continue
+		}
+		for name, v := range w.Values {
+			if d.Values[name] != v {
+				t.Errorf("wrong %s value for %s wanted %q got %q", d.Kind, name, v, d.Values[name])
+			}
+		}
+	}
+}
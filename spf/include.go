@@ -1,24 +1,34 @@
 package spf
 
 import (
+	"errors"
+	"fmt"
 	"net"
 )
 
+// Include is the include mechanism. Provider is the known provider the
+// included domain belongs to, nil if there is none in the catalog.
 type Include struct {
 	Qualifier Qualifier
 	Domain    string
 	Record    string
+	Provider  *Provider
 	r         resolver
 	spf       SPF
+	o         Observer
 }
 
-func NewInclude(record string, res resolver) (Include, error) {
+func NewInclude(record string, res resolver, opts ...Option) (Include, error) {
 	q, d, err := matchInclude(record)
 	if err != nil {
 		return Include{}, err
 	}
-	spf, err := New(d, res)
-	if err != nil {
+	spf, err := New(d, res, opts...)
+	if errors.Is(err, NoSPFRecordPublished) && !spf.voidIncludes {
+		// none for the included domain is a permerror of the including one
+		return Include{}, fmt.Errorf("%w - %s: %v", PermanentError, record, err)
+	}
+	if err != nil && !errors.Is(err, NoSPFRecordPublished) {
 		return Include{}, err
 	}
 	i := Include{
@@ -27,17 +37,38 @@ func NewInclude(record string, res resolver) (Include, error) {
 		Record:    record,
 		Domain:    d,
 		spf:       spf,
+		o:         spf.o,
 	}
+	i.Provider, _ = spf.providers().ByInclude(d)
 	return i, nil
 }
 
+// Match evaluates the included record as a whole, all included, and matches
+// only when it gives pass, as RFC 7208 section 5.2 requires. fail, softfail
+// and neutral do not match; an error of the included evaluation, temporary
+// or permanent, is returned as it is.
 func (i Include) Match(ip net.IP) ([]string, error) {
-	m, err := i.spf.Match(ip)
+	if i.o != nil {
+		i.o.OnIncludeEnter(i.Domain, i.Record)
+	}
+	q, m, err := i.spf.evaluate(ip, true)
+	matched := err == nil && q == Pass && len(m) > 0
+	if i.o != nil {
+		i.o.OnIncludeExit(i.Domain, i.Record, matched)
+	}
 	if err != nil {
 		return []string{}, err
 	}
-	if len(m) > 0 {
-		m = append([]string{i.Record}, m...)
+	if !matched {
+		return []string{}, nil
 	}
-	return m, nil
+	return append([]string{i.Record}, m...), nil
+}
+
+func (i Include) String() string {
+	return qualifierPrefix(i.Qualifier) + "include:" + domainSpec(i.Domain)
+}
+
+func (i Include) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
 }
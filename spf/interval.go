@@ -0,0 +1,108 @@
+package spf
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+)
+
+// addrInterval is an inclusive range of addresses of a single family.
+type addrInterval struct {
+	From netip.Addr
+	To   netip.Addr
+}
+
+// Prefixes returns the fewest prefixes exactly covering the interval.
+func (r addrInterval) Prefixes() (prefixes []netip.Prefix) {
+	from := r.From
+	for from.IsValid() && from.Compare(r.To) <= 0 {
+		var p netip.Prefix
+		for bits := 0; bits <= from.BitLen(); bits++ {
+			p = netip.PrefixFrom(from, bits).Masked()
+			if p.Addr() == from && lastAddr(p).Compare(r.To) <= 0 {
+				break
+			}
+		}
+		prefixes = append(prefixes, p)
+		if lastAddr(p) == r.To {
+			break
+		}
+		from = lastAddr(p).Next()
+	}
+	return
+}
+
+// lastAddr returns the highest address of p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(a)*8; i++ {
+		a[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(a)
+	return addr
+}
+
+var familySpaces = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/0"),
+	netip.MustParsePrefix("::/0"),
+}
+
+// mappedSpace holds the IPv4-mapped IPv6 addresses, which are left out of
+// the IPv6 intervals because they are the IPv4 addresses once more.
+var mappedSpace = netip.MustParsePrefix("::ffff:0:0/96")
+
+// elementaryIntervals splits both address families at the edges of every
+// prefix, so that each interval is either inside or outside any prefix.
+func elementaryIntervals(prefixes []netip.Prefix) (intervals []addrInterval) {
+	for _, space := range familySpaces {
+		edges := map[netip.Addr]bool{space.Addr(): true}
+		if !space.Addr().Is4() {
+			edges[mappedSpace.Addr()] = true
+			edges[lastAddr(mappedSpace).Next()] = true
+		}
+		for _, p := range prefixes {
+			if p.Addr().Is4() != space.Addr().Is4() {
+				continue
+			}
+			edges[p.Masked().Addr()] = true
+			if next := lastAddr(p).Next(); next.IsValid() {
+				edges[next] = true
+			}
+		}
+		starts := make([]netip.Addr, 0, len(edges))
+		for a := range edges {
+			starts = append(starts, a)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Less(starts[j]) })
+		for i, from := range starts {
+			to := lastAddr(space)
+			if i+1 < len(starts) {
+				to = starts[i+1].Prev()
+			}
+			if mappedSpace.Contains(from) {
+				continue
+			}
+			intervals = append(intervals, addrInterval{From: from, To: to})
+		}
+	}
+	return
+}
+
+// treePrefixes returns the networks of every mechanism of the tree.
+func treePrefixes(spf *SPF) (prefixes []netip.Prefix) {
+	spf.walk(func(path []string, m Mechanism) {
+		prefixes = append(prefixes, networkPrefixes(m)...)
+	})
+	return
+}
+
+// verdictAt evaluates spf for a single address, an error names the address
+// it happened at.
+func verdictAt(spf *SPF, addr netip.Addr) (Qualifier, []string, error) {
+	q, chain, err := spf.Verdict(net.IP(addr.AsSlice()))
+	if err != nil {
+		return q, chain, fmt.Errorf("%w - evaluating %s", err, addr)
+	}
+	return q, chain, nil
+}
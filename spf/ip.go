@@ -43,3 +43,16 @@ func (i IP) Match(ip net.IP) (m []string, errRtn error) {
 	}
 	return
 }
+
+// String returns the canonical form of the term with the network address
+// masked and the prefix length left out for single addresses.
+func (i IP) String() string {
+	if i.Network == nil {
+		return qualifierPrefix(i.Qualifier) + "ip4"
+	}
+	return prefixTerm(i.Qualifier, prefixOf(i.Network))
+}
+
+func (i IP) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
@@ -17,6 +17,7 @@ func TestNewIPHappyPath(t *testing.T) {
 		{"2001:4860:4000::/36", "6", "2001:4860:4000::/36"},
 		{"2800:3f0:4000::/36", "6", "2800:3f0:4000::/36"},
 		{"2800:3f0:4000::", "6", "2800:3f0:4000::/128"},
+		{"2001:db8::/32", "6", "2001:db8::/32"},
 	}
 	for _, testCase := range TestTable {
 		record := fmt.Sprintf("ip%s:%s", testCase.v, testCase.ip)
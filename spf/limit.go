@@ -0,0 +1,148 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	TemporaryError       = errors.New("temporary dns failure")
+	QueryBudgetExhausted = errors.New("query budget exhausted")
+)
+
+// LimitedResolver keeps bulk evaluations within the limits of an upstream
+// resolver. Queries are spaced to at most qps per second (a token bucket
+// holding a single token), at most maxInFlight run concurrently and the whole
+// job may make at most budget queries. A limit of zero or less disables it.
+// Queries over the budget fail right away with an error wrapping
+// TemporaryError and QueryBudgetExhausted.
+type LimitedResolver struct {
+	r        resolver
+	interval time.Duration
+	inFlight chan struct{}
+
+	mu     sync.Mutex
+	next   time.Time
+	budget int
+	used   int
+}
+
+func NewLimitedResolver(res resolver, qps float64, maxInFlight int, budget int) *LimitedResolver {
+	l := &LimitedResolver{r: res, budget: budget}
+	if qps > 0 {
+		l.interval = time.Duration(float64(time.Second) / qps)
+	}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// Used returns how many queries were let through so far.
+func (l *LimitedResolver) Used() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used
+}
+
+// ResetBudget starts a new job with a fresh budget.
+func (l *LimitedResolver) ResetBudget(budget int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.budget = budget
+	l.used = 0
+}
+
+// acquire takes one query from the budget, waits for its turn in the rate
+// limit and for a free in-flight slot. The returned function releases the
+// slot.
+func (l *LimitedResolver) acquire(domain string) (func(), error) {
+	l.mu.Lock()
+	if l.budget > 0 && l.used >= l.budget {
+		l.mu.Unlock()
+		return nil, fmt.Errorf("%w - %w after %d queries at %s", TemporaryError, QueryBudgetExhausted, l.budget, domain)
+	}
+	l.used++
+	var wait time.Duration
+	if l.interval > 0 {
+		now := time.Now()
+		if l.next.Before(now) {
+			l.next = now
+		}
+		wait = l.next.Sub(now)
+		l.next = l.next.Add(l.interval)
+	}
+	l.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+	l.inFlight <- struct{}{}
+	return func() { <-l.inFlight }, nil
+}
+
+func (l *LimitedResolver) TextRecord(domain string) ([]string, error) {
+	release, err := l.acquire(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.r.TextRecord(domain)
+}
+
+func (l *LimitedResolver) ARecord(domain string) ([]net.IP, error) {
+	release, err := l.acquire(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.r.ARecord(domain)
+}
+
+func (l *LimitedResolver) MXRecord(domain string) ([]*net.MX, error) {
+	release, err := l.acquire(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return l.r.MXRecord(domain)
+}
+
+func (l *LimitedResolver) TextStrings(domain string) ([][]string, error) {
+	release, err := l.acquire(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return textStrings(l.r, domain)
+}
+
+func (l *LimitedResolver) SPFTypeRecord(domain string) ([]string, error) {
+	release, err := l.acquire(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return spfTypeRecord(l.r, domain)
+}
+
+func (l *LimitedResolver) NSRecord(domain string) ([]*net.NS, error) {
+	release, err := l.acquire(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return nsRecord(l.r, domain)
+}
+
+func (l *LimitedResolver) TTL(domain string, rtype string) (uint32, bool) {
+	if t, ok := l.r.(ttlResolver); ok {
+		return t.TTL(domain, rtype)
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type slowResolver struct {
+	MockResolver
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (s *slowResolver) ARecord(domain string) ([]net.IP, error) {
+	s.mu.Lock()
+	s.current++
+	if s.current > s.max {
+		s.max = s.current
+	}
+	s.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	s.mu.Lock()
+	s.current--
+	s.mu.Unlock()
+	return s.MockResolver.ARecord(domain)
+}
+
+func TestLimitedResolverBudget(t *testing.T) {
+	l := NewLimitedResolver(loadTestZone(t), 0, 0, 3)
+	_, err := New("test.com", l)
+	if !errors.Is(err, QueryBudgetExhausted) || !errors.Is(err, TemporaryError) {
+		t.Errorf("expected temporary budget error got %v", err)
+	}
+	if l.Used() != 3 {
+		t.Errorf("expected 3 queries to be used got %d", l.Used())
+	}
+	_, err = New("_spf.test.com", l)
+	if !errors.Is(err, QueryBudgetExhausted) {
+		t.Errorf("TXT lookup over budget should fail with budget error got %v", err)
+	}
+	l.ResetBudget(10)
+	if _, err := New("test.com", l); err != nil {
+		t.Errorf("creating SPF within budget should not have failed but got %q", err)
+	}
+}
+
+func TestLimitedResolverRate(t *testing.T) {
+	l := NewLimitedResolver(loadTestZone(t), 50, 0, 0)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := l.TextRecord("test.com"); err != nil {
+			t.Fatalf("lookup should not have failed but got %q", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 queries at 50 qps should take at least 80ms took %s", elapsed)
+	}
+}
+
+func TestLimitedResolverInFlight(t *testing.T) {
+	s := &slowResolver{}
+	l := NewLimitedResolver(s, 0, 2, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.ARecord("test.com")
+		}()
+	}
+	wg.Wait()
+	if s.max != 2 {
+		t.Errorf("expected at most 2 queries in flight got %d", s.max)
+	}
+}
+
+func TestLimitedResolverTXTVariants(t *testing.T) {
+	l := NewLimitedResolver(loadTestZone(t), 0, 0, 2)
+	if txt, err := l.TextStrings("test.com"); err != nil || len(txt) == 0 {
+		t.Errorf("TextStrings should have been forwarded got %v %v", txt, err)
+	}
+	if _, err := l.SPFTypeRecord("test.com"); errors.Is(err, NotSupported) {
+		t.Errorf("SPFTypeRecord should have been forwarded got %v", err)
+	}
+	if l.Used() != 2 {
+		t.Errorf("both queries should have been taken from the budget got %d", l.Used())
+	}
+	if _, err := l.TextStrings("test.com"); !errors.Is(err, QueryBudgetExhausted) {
+		t.Errorf("TextStrings over budget should fail with budget error got %v", err)
+	}
+	if _, err := l.SPFTypeRecord("test.com"); !errors.Is(err, QueryBudgetExhausted) {
+		t.Errorf("SPFTypeRecord over budget should fail with budget error got %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MaxLookups     = 10
+	MaxVoidLookups = 2
+	maxTXTString   = 255
+	maxUDPResponse = 512
+	// lintLookupLimit bounds the lookups Lint resolves. It is well above
+	// MaxLookups so that a record over the limit is still checked as a whole.
+	lintLookupLimit = 10 * MaxLookups
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Codes of the lint findings. They are stable and safe to match on.
+const (
+	LintPassAll          = "SPF001"
+	LintNeutralAll       = "SPF002"
+	LintMissingAll       = "SPF003"
+	LintTermsAfterAll    = "SPF004"
+	LintPTR              = "SPF005"
+	LintTooManyLookups   = "SPF006"
+	LintTooManyVoid      = "SPF007"
+	LintStringTooLong    = "SPF008"
+	LintResponseTooLarge = "SPF009"
+	LintDuplicateInclude = "SPF010"
+	LintSPFRecordType    = "SPF011"
+	LintUppercase        = "SPF012"
+	LintWhitespace       = "SPF013"
+	LintMultipleRecords  = "SPF014"
+	LintRedirectIgnored  = "SPF015"
+	LintBroadNetwork     = "SPF016"
+	LintLookupLoop       = "SPF017"
+)
+
+// Finding is a single problem found by Lint in the record of Domain. Term is
+// the offending term, empty when the finding is about the whole record.
+// Provider is the known provider the offending include, or the record
+// itself, belongs to.
+type Finding struct {
+	Code     string
+	Severity Severity
+	Domain   string
+	Term     string
+	Message  string
+	Provider *Provider
+}
+
+// Lint builds the record of domain and checks it and every record reached
+// through include and redirect against RFC 7208 limits and common best
+// practice. A tree that loops, or that needs so many lookups that Lint
+// stops resolving it, is reported as a single finding.
+func Lint(domain string, res resolver, opts ...Option) ([]Finding, error) {
+	spf, err := New(domain, res, append(opts[:len(opts):len(opts)], withVoidIncludes())...)
+	switch {
+	case errors.Is(err, LookupLoop):
+		return []Finding{{
+			Code:     LintLookupLoop,
+			Severity: SeverityError,
+			Domain:   domain,
+			Message:  fmt.Sprintf("evaluation never ends: %s", err),
+		}}, nil
+	case errors.Is(err, TooManyLookups):
+		return []Finding{{
+			Code:     LintTooManyLookups,
+			Severity: SeverityError,
+			Domain:   domain,
+			Message:  fmt.Sprintf("evaluation needs more than %d DNS lookups, the limit is %d", lintLookupLimit, MaxLookups),
+		}}, nil
+	case err != nil:
+		return nil, err
+	}
+	var findings []Finding
+	records := []*SPF{&spf}
+	seen := map[string]bool{canonicalName(spf.Domain): true}
+	spf.walk(func(path []string, m Mechanism) {
+		var r *SPF
+		switch v := m.(type) {
+		case Include:
+			r = &v.spf
+		case Redirect:
+			r = &v.spf
+		default:
+			return
+		}
+		if !seen[canonicalName(r.Domain)] && r.Record != "" {
+			seen[canonicalName(r.Domain)] = true
+			records = append(records, r)
+		}
+	})
+	for _, r := range records {
+		findings = append(findings, lintRecord(r, res)...)
+	}
+	for _, b := range spf.BroadAuthorizations(DefaultBroadThreshold) {
+		limit := DefaultBroadThreshold.IPv6
+		if b.Prefix.Addr().Is4() {
+			limit = DefaultBroadThreshold.IPv4
+		}
+		findings = append(findings, Finding{
+			Code:     LintBroadNetwork,
+			Severity: SeverityWarning,
+			Domain:   b.Domain,
+			Term:     b.Term,
+			Message:  fmt.Sprintf("%s passes %s, a network larger than a /%d", b.Term, b.Prefix, limit),
+		})
+	}
+	if n := lookupCount(&spf); n > MaxLookups {
+		findings = append(findings, Finding{
+			Code:     LintTooManyLookups,
+			Severity: SeverityError,
+			Domain:   spf.Domain,
+			Message:  fmt.Sprintf("evaluation needs %d DNS lookups, more than the limit of %d", n, MaxLookups),
+		})
+	}
+	if n := voidLookupCount(&spf); n > MaxVoidLookups {
+		findings = append(findings, Finding{
+			Code:     LintTooManyVoid,
+			Severity: SeverityError,
+			Domain:   spf.Domain,
+			Message:  fmt.Sprintf("%d DNS lookups return no answer, more than the limit of %d", n, MaxVoidLookups),
+		})
+	}
+	catalog := spf.providers()
+	for i, f := range findings {
+		domain := f.Domain
+		if termName(f.Term) == "include" {
+			domain = f.Term[strings.IndexByte(f.Term, ':')+1:]
+		}
+		findings[i].Provider, _ = catalog.ByInclude(domain)
+	}
+	return findings, nil
+}
+
+func lintRecord(spf *SPF, res resolver) (findings []Finding) {
+	add := func(code string, severity Severity, term string, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Code:     code,
+			Severity: severity,
+			Domain:   spf.Domain,
+			Term:     term,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	if strings.TrimSpace(spf.Record) != spf.Record || strings.Contains(spf.Record, "  ") || strings.ContainsAny(spf.Record, "\t\r\n") {
+		add(LintWhitespace, SeverityWarning, "", "record contains leading, trailing or repeated whitespace")
+	}
+	allSeen := ""
+	hasRedirect := false
+	includes := make(map[string]bool)
+	for _, term := range strings.Fields(spf.Record) {
+		if term != strings.ToLower(term) {
+			add(LintUppercase, SeverityInfo, term, "term is not lower case")
+		}
+		name := termName(term)
+		switch {
+		case name == "all":
+			switch term[0] {
+			case '+', 'a', 'A':
+				add(LintPassAll, SeverityError, term, "%s authorizes every host on the internet", term)
+			case '?':
+				add(LintNeutralAll, SeverityWarning, term, "%s gives no protection against spoofing", term)
+			}
+		case name == "ptr":
+			add(LintPTR, SeverityWarning, term, "ptr is slow, unreliable and should not be used (RFC 7208 5.5)")
+		case name == "include":
+			target := canonicalName(term[strings.IndexByte(term, ':')+1:])
+			if includes[target] {
+				add(LintDuplicateInclude, SeverityWarning, term, "%s is included more than once", target)
+			}
+			includes[target] = true
+		case name == "redirect":
+			hasRedirect = true
+		}
+		if allSeen != "" && name != "all" && !strings.Contains(term, "=") {
+			add(LintTermsAfterAll, SeverityWarning, term, "term after %s is never evaluated", allSeen)
+		}
+		if name == "all" && allSeen == "" {
+			allSeen = term
+		}
+	}
+	if allSeen == "" && !hasRedirect {
+		add(LintMissingAll, SeverityWarning, "", "record has no all mechanism, unmatched hosts get neutral")
+	}
+	if allSeen != "" && hasRedirect {
+		add(LintRedirectIgnored, SeverityWarning, "", "redirect is ignored because the record has an all mechanism")
+	}
+	findings = append(findings, lintPublishing(spf, res)...)
+	return
+}
+
+// lintPublishing checks how the record is published: the number of SPF
+// records, the size of strings and responses and the deprecated SPF type.
+func lintPublishing(spf *SPF, res resolver) (findings []Finding) {
+	var records [][]string
+	if r, ok := res.(txtStringsResolver); ok {
+		txt, err := r.TextStrings(spf.Domain)
+		if err == nil {
+			records = txt
+		}
+	}
+	if records == nil {
+		txt, _ := res.TextRecord(spf.Domain)
+		for _, v := range txt {
+			records = append(records, splitTXT(v))
+		}
+	}
+	spfRecords := 0
+	for _, strs := range records {
+		if strings.HasPrefix(strings.Join(strs, ""), "v=spf1") {
+			spfRecords++
+		}
+		for _, s := range strs {
+			if len(s) > maxTXTString {
+				findings = append(findings, Finding{
+					Code:     LintStringTooLong,
+					Severity: SeverityError,
+					Domain:   spf.Domain,
+					Message:  fmt.Sprintf("TXT string of %d characters is longer than %d", len(s), maxTXTString),
+				})
+			}
+		}
+	}
+	if spfRecords > 1 {
+		findings = append(findings, Finding{
+			Code:     LintMultipleRecords,
+			Severity: SeverityError,
+			Domain:   spf.Domain,
+			Message:  fmt.Sprintf("%d SPF records are published, evaluation fails with permerror", spfRecords),
+		})
+	}
+	if size := txtResponseSize(spf.Domain, records); size > maxUDPResponse {
+		findings = append(findings, Finding{
+			Code:     LintResponseTooLarge,
+			Severity: SeverityWarning,
+			Domain:   spf.Domain,
+			Message:  fmt.Sprintf("TXT response of %d bytes is larger than %d and needs EDNS0 or TCP", size, maxUDPResponse),
+		})
+	}
+	if r, ok := res.(spfTypeResolver); ok {
+		if v, err := r.SPFTypeRecord(spf.Domain); err == nil && len(v) > 0 {
+			findings = append(findings, Finding{
+				Code:     LintSPFRecordType,
+				Severity: SeverityWarning,
+				Domain:   spf.Domain,
+				Message:  "record is also published as the deprecated SPF type 99 (RFC 7208 3.1)",
+			})
+		}
+	}
+	return
+}
+
+// splitTXT splits a record into the strings it needs at least to be
+// published.
+func splitTXT(record string) (strs []string) {
+	for len(record) > maxTXTString {
+		strs = append(strs, record[:maxTXTString])
+		record = record[maxTXTString:]
+	}
+	return append(strs, record)
+}
+
+// txtResponseSize estimates the size of a DNS response carrying records for
+// domain: header, question and one answer per record with a compressed owner.
+func txtResponseSize(domain string, records [][]string) int {
+	size := 12 + len(canonicalName(domain)) + 2 + 4
+	for _, strs := range records {
+		size += 2 + 10
+		for _, s := range strs {
+			size += 1 + len(s)
+		}
+	}
+	return size
+}
+
+// lookupTerms are the terms that cost a DNS lookup (RFC 7208 4.6.4).
+var lookupTerms = map[string]bool{
+	"a":        true,
+	"mx":       true,
+	"ptr":      true,
+	"exists":   true,
+	"include":  true,
+	"redirect": true,
+}
+
+// ownLookups returns the number of lookups the terms of a single record
+// cost, without the records they include.
+func ownLookups(record string) (n int) {
+	for _, term := range strings.Fields(record) {
+		if lookupTerms[termName(term)] {
+			n++
+		}
+	}
+	return
+}
+
+// lookupCount returns the number of lookups evaluating spf can cost,
+// including every record reached through include and redirect.
+func lookupCount(spf *SPF) int {
+	n := ownLookups(spf.Record)
+	spf.walk(func(path []string, m Mechanism) {
+		switch v := m.(type) {
+		case Include:
+			n += ownLookups(v.spf.Record)
+		case Redirect:
+			n += ownLookups(v.spf.Record)
+		}
+	})
+	return n
+}
+
+// withVoidIncludes makes includes of domains without a record void lookups
+// instead of errors.
+func withVoidIncludes() Option {
+	return func(spf *SPF) {
+		spf.voidIncludes = true
+	}
+}
+
+// voidLookupCount returns the number of void lookups in the tree.
+func voidLookupCount(spf *SPF) (n int) {
+	spf.walk(func(path []string, m Mechanism) {
+		if isVoid(m) {
+			n++
+		}
+	})
+	return
+}
+
+// isVoid reports whether m is an a or mx lookup that did not yield any
+// address, or an include of a domain without a record.
+func isVoid(m Mechanism) bool {
+	switch v := m.(type) {
+	case Include:
+		return v.spf.Record == ""
+	case A:
+		return len(v.Networks) == 0
+	case MX:
+		return len(v.Networks) == 0
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package spf
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+const lintZone = `
+$ORIGIN test.com.
+@        TXT "v=spf1 a include:_spf.test.com include:_spf.test.com ?all ip4:10.0.0.1"
+@        A   192.168.1.1
+@        SPF "v=spf1 -all"
+_spf     TXT "v=spf1 ptr a:empty.test.com mx:empty.test.com a:empty2.test.com -all"
+empty    TXT "no addresses here"
+empty2   TXT "no addresses here"
+passall  TXT "v=spf1 +all"
+noall    TXT "v=spf1  IP4:10.0.0.1 redirect=_spf.test.com"
+both     TXT "v=spf1 -all redirect=_spf.test.com"
+broad    TXT "v=spf1 ip4:10.0.0.0/8 -ip4:172.16.0.0/12 -all"
+multi    TXT "v=spf1 -all"
+multi    TXT "v=spf1 ~all"
+long     TXT ( "v=spf1 "
+               "ip4:10.0.0.1 ip4:10.0.0.2 ip4:10.0.0.3 ip4:10.0.0.4 ip4:10.0.0.5 ip4:10.0.0.6 ip4:10.0.0.7 ip4:10.0.0.8 ip4:10.0.0.9 "
+               "ip4:10.0.1.1 ip4:10.0.1.2 ip4:10.0.1.3 ip4:10.0.1.4 ip4:10.0.1.5 ip4:10.0.1.6 ip4:10.0.1.7 ip4:10.0.1.8 ip4:10.0.1.9 "
+               "ip4:10.0.2.1 ip4:10.0.2.2 ip4:10.0.2.3 ip4:10.0.2.4 ip4:10.0.2.5 ip4:10.0.2.6 ip4:10.0.2.7 ip4:10.0.2.8 ip4:10.0.2.9 "
+               "ip4:10.0.3.1 ip4:10.0.3.2 ip4:10.0.3.3 ip4:10.0.3.4 ip4:10.0.3.5 ip4:10.0.3.6 ip4:10.0.3.7 ip4:10.0.3.8 ip4:10.0.3.9 "
+               "ip4:10.0.4.1 ip4:10.0.4.2 ip4:10.0.4.3 ip4:10.0.4.4 ip4:10.0.4.5 ip4:10.0.4.6 ip4:10.0.4.7 ip4:10.0.4.8 ip4:10.0.4.9 "
+               "-all" )
+deep     TXT "v=spf1 include:a.deep.test.com include:b.deep.test.com include:c.deep.test.com -all"
+a.deep   TXT "v=spf1 a a a a -all"
+b.deep   TXT "v=spf1 a a a -all"
+c.deep   TXT "v=spf1 a -all"
+gone     TXT "v=spf1 a:nx.test.com mx:nx.test.com include:nx.test.com -all"
+loop     TXT "v=spf1 ip4:10.0.0.1 redirect=loop.test.com"
+loopa    TXT "v=spf1 include:loopb.test.com -all"
+loopb    TXT "v=spf1 ip4:10.0.0.1 include:loopa.test.com -all"
+wide     TXT "v=spf1 include:wide1.test.com include:wide1.test.com -all"
+wide1    TXT "v=spf1 include:wide2.test.com include:wide2.test.com -all"
+wide2    TXT "v=spf1 include:wide3.test.com include:wide3.test.com -all"
+wide3    TXT "v=spf1 include:wide4.test.com include:wide4.test.com -all"
+wide4    TXT "v=spf1 include:wide5.test.com include:wide5.test.com -all"
+wide5    TXT "v=spf1 include:wide6.test.com include:wide6.test.com -all"
+wide6    TXT "v=spf1 ip4:10.0.0.1 -all"
+a.deep   A   10.0.0.1
+b.deep   A   10.0.0.2
+c.deep   A   10.0.0.3
+`
+
+func TestLint(t *testing.T) {
+	z := NewZoneResolver()
+	if err := z.Load(strings.NewReader(lintZone), ""); err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+	TestTable := []struct {
+		domain string
+		codes  []string
+	}{
+		{"test.com", []string{LintDuplicateInclude, LintNeutralAll, LintTermsAfterAll,
+			LintSPFRecordType, LintPTR, LintTooManyVoid, LintTooManyLookups}},
+		{"passall.test.com", []string{LintPassAll}},
+		{"noall.test.com", []string{LintUppercase, LintWhitespace, LintPTR, LintTooManyVoid}},
+		{"both.test.com", []string{LintRedirectIgnored, LintPTR, LintTooManyVoid}},
+		{"multi.test.com", []string{LintMultipleRecords}},
+		{"broad.test.com", []string{LintBroadNetwork}},
+		{"long.test.com", []string{LintResponseTooLarge}},
+		{"deep.test.com", []string{LintTooManyLookups}},
+		{"gone.test.com", []string{LintTooManyVoid}},
+		{"loop.test.com", []string{LintLookupLoop}},
+		{"loopa.test.com", []string{LintLookupLoop}},
+		{"wide.test.com", []string{LintTooManyLookups}},
+	}
+	for _, testCase := range TestTable {
+		findings, err := Lint(testCase.domain, z)
+		if err != nil {
+			t.Fatalf("lint of %s should not have failed but got %q", testCase.domain, err)
+		}
+		var codes []string
+		for _, f := range findings {
+			codes = append(codes, f.Code)
+		}
+		sort.Strings(codes)
+		sort.Strings(testCase.codes)
+		if strings.Join(codes, " ") != strings.Join(testCase.codes, " ") {
+			t.Errorf("wrong findings for %s wanted %v got %+v", testCase.domain, testCase.codes, findings)
+		}
+	}
+}
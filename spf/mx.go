@@ -25,16 +25,32 @@ func (mx MX) Match(ip net.IP) (m []string, errRtn error) {
 	return
 }
 
+// String returns the canonical form of the term. The domain is only written
+// when the record names one explicitly.
+func (mx MX) String() string {
+	return amxString(mx.Qualifier, "mx", mx.Record, mx.Domain, mx.CIDR4, mx.CIDR6)
+}
+
+func (mx MX) MarshalText() ([]byte, error) {
+	return []byte(mx.String()), nil
+}
+
 func extractMXrecordIPs(res resolver, domain string, cidr4 string, cidr6 string) (ListOfNetworks []*net.IPNet, errRtn error) {
 	mxRecords, err := res.MXRecord(domain)
+	if isNotFound(err) {
+		return
+	}
 	if err != nil {
-		errRtn = fmt.Errorf("%w - %s", DNSResolutionError, err)
+		errRtn = fmt.Errorf("%w - %w", DNSResolutionError, err)
 		return
 	}
 	for _, mx := range mxRecords {
 		ips, err := res.ARecord(mx.Host)
+		if isNotFound(err) {
+			continue
+		}
 		if err != nil {
-			errRtn = fmt.Errorf("%w - %s", DNSResolutionError, err)
+			errRtn = fmt.Errorf("%w - %w", DNSResolutionError, err)
 			return
 		}
 		if cidr4 == "" {
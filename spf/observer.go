@@ -0,0 +1,93 @@
+package spf
+
+import (
+	"net"
+)
+
+// Observer is notified about every DNS query and every evaluation step, so
+// logging, metrics or tracing can be hooked in without wrapping each type.
+// Callbacks run synchronously on the goroutine doing the work.
+type Observer interface {
+	OnQuery(domain string, rtype string)
+	OnAnswer(domain string, rtype string, answers int, err error)
+	OnMechanismEvaluated(domain string, term string, matched bool)
+	OnIncludeEnter(domain string, term string)
+	OnIncludeExit(domain string, term string, matched bool)
+	OnResult(domain string, ip net.IP, match []string, err error)
+}
+
+// NopObserver ignores every callback. Embed it to implement only some of
+// them.
+type NopObserver struct{}
+
+func (NopObserver) OnQuery(string, string)                    {}
+func (NopObserver) OnAnswer(string, string, int, error)       {}
+func (NopObserver) OnMechanismEvaluated(string, string, bool) {}
+func (NopObserver) OnIncludeEnter(string, string)             {}
+func (NopObserver) OnIncludeExit(string, string, bool)        {}
+func (NopObserver) OnResult(string, net.IP, []string, error)  {}
+
+// Option changes how New builds and evaluates a record. Options are passed
+// down to included and redirected records.
+type Option func(*SPF)
+
+func WithObserver(o Observer) Option {
+	return func(spf *SPF) {
+		spf.o = o
+	}
+}
+
+// observedResolver reports every query of the wrapped resolver to o.
+type observedResolver struct {
+	r resolver
+	o Observer
+}
+
+func (r observedResolver) TextRecord(domain string) ([]string, error) {
+	r.o.OnQuery(domain, "TXT")
+	txt, err := r.r.TextRecord(domain)
+	r.o.OnAnswer(domain, "TXT", len(txt), err)
+	return txt, err
+}
+
+func (r observedResolver) ARecord(domain string) ([]net.IP, error) {
+	r.o.OnQuery(domain, "A")
+	ips, err := r.r.ARecord(domain)
+	r.o.OnAnswer(domain, "A", len(ips), err)
+	return ips, err
+}
+
+func (r observedResolver) MXRecord(domain string) ([]*net.MX, error) {
+	r.o.OnQuery(domain, "MX")
+	mxs, err := r.r.MXRecord(domain)
+	r.o.OnAnswer(domain, "MX", len(mxs), err)
+	return mxs, err
+}
+
+func (r observedResolver) NSRecord(domain string) ([]*net.NS, error) {
+	r.o.OnQuery(domain, "NS")
+	ns, err := nsRecord(r.r, domain)
+	r.o.OnAnswer(domain, "NS", len(ns), err)
+	return ns, err
+}
+
+func (r observedResolver) TextStrings(domain string) ([][]string, error) {
+	r.o.OnQuery(domain, "TXT")
+	txt, err := textStrings(r.r, domain)
+	r.o.OnAnswer(domain, "TXT", len(txt), err)
+	return txt, err
+}
+
+func (r observedResolver) SPFTypeRecord(domain string) ([]string, error) {
+	r.o.OnQuery(domain, "SPF")
+	spf, err := spfTypeRecord(r.r, domain)
+	r.o.OnAnswer(domain, "SPF", len(spf), err)
+	return spf, err
+}
+
+func (r observedResolver) TTL(domain string, rtype string) (uint32, bool) {
+	if t, ok := r.r.(ttlResolver); ok {
+		return t.TTL(domain, rtype)
+	}
+	return 0, false
+}
@@ -0,0 +1,80 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+type recordingObserver struct {
+	NopObserver
+	events []string
+}
+
+func (o *recordingObserver) OnQuery(domain string, rtype string) {
+	o.events = append(o.events, "query "+rtype+" "+domain)
+}
+
+func (o *recordingObserver) OnMechanismEvaluated(domain string, term string, matched bool) {
+	if matched {
+		o.events = append(o.events, "match "+domain+" "+term)
+	}
+}
+
+func (o *recordingObserver) OnIncludeEnter(domain string, term string) {
+	o.events = append(o.events, "enter "+term)
+}
+
+func (o *recordingObserver) OnIncludeExit(domain string, term string, matched bool) {
+	o.events = append(o.events, "exit "+term)
+}
+
+func (o *recordingObserver) OnResult(domain string, ip net.IP, match []string, err error) {
+	o.events = append(o.events, "result "+domain)
+}
+
+func TestObserver(t *testing.T) {
+	o := &recordingObserver{}
+	spf, err := New("test.com", loadTestZone(t), WithObserver(o))
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	if _, err := spf.Match(net.ParseIP("172.16.0.1")); err != nil {
+		t.Fatalf("matching IP should not have failed but got %q", err)
+	}
+	want := []string{
+		"query TXT test.com",
+		"query A test.com",
+		"query MX test.com",
+		"query A mx.test.com.",
+		"query TXT _spf.test.com",
+		"enter include:_spf.test.com",
+		"match _spf.test.com ip4:172.16.0.0/16",
+		"exit include:_spf.test.com",
+		"match test.com include:_spf.test.com",
+		"result test.com",
+	}
+	if !reflect.DeepEqual(o.events, want) {
+		t.Errorf("wrong events wanted\n%q\ngot\n%q", want, o.events)
+	}
+}
+
+func TestObservedResolverOptionalQueries(t *testing.T) {
+	o := &recordingObserver{}
+	r := observedResolver{r: loadTestZone(t), o: o}
+	strs, err := r.TextStrings("test.com")
+	if err != nil || !reflect.DeepEqual(strs, [][]string{{"v=spf1 a mx include:_spf.test.com ", "-all"}}) {
+		t.Errorf("TXT strings were not forwarded got %q %v", strs, err)
+	}
+	if _, err := r.SPFTypeRecord("test.com"); errors.Is(err, NotSupported) {
+		t.Errorf("SPF type lookup was not forwarded got %v", err)
+	}
+	if !reflect.DeepEqual(o.events, []string{"query TXT test.com", "query SPF test.com"}) {
+		t.Errorf("optional queries were not observed got %q", o.events)
+	}
+	r = observedResolver{r: MockResolver{}, o: o}
+	if _, err := r.TextStrings("test.com"); !errors.Is(err, NotSupported) {
+		t.Errorf("resolver without TXT strings should answer NotSupported got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package spf
+
+import (
+	"net"
+	"sync"
+)
+
+// OverlayResolver serves static answers for chosen names and record types and
+// passes every other query to the underlying resolver. It is meant for what-if
+// evaluations such as trying an unpublished SPF record against live DNS.
+type OverlayResolver struct {
+	r        resolver
+	mu       sync.RWMutex
+	txt      map[string][]string
+	a        map[string][]net.IP
+	mx       map[string][]*net.MX
+	nxdomain map[string]bool
+}
+
+func NewOverlayResolver(res resolver) *OverlayResolver {
+	return &OverlayResolver{
+		r:        res,
+		txt:      make(map[string][]string),
+		a:        make(map[string][]net.IP),
+		mx:       make(map[string][]*net.MX),
+		nxdomain: make(map[string]bool),
+	}
+}
+
+// SetTXT replaces every TXT record of domain with txt.
+func (o *OverlayResolver) SetTXT(domain string, txt ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.txt[canonicalName(domain)] = txt
+}
+
+// SetA replaces every A and AAAA record of domain with ips.
+func (o *OverlayResolver) SetA(domain string, ips ...net.IP) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.a[canonicalName(domain)] = ips
+}
+
+// SetMX replaces every MX record of domain with mxs.
+func (o *OverlayResolver) SetMX(domain string, mxs ...*net.MX) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.mx[canonicalName(domain)] = mxs
+}
+
+// SetNXDOMAIN makes every query for domain fail as if it did not exist.
+func (o *OverlayResolver) SetNXDOMAIN(domain string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.nxdomain[canonicalName(domain)] = true
+}
+
+// Reset drops every override of domain.
+func (o *OverlayResolver) Reset(domain string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	name := canonicalName(domain)
+	delete(o.txt, name)
+	delete(o.a, name)
+	delete(o.mx, name)
+	delete(o.nxdomain, name)
+}
+
+func (o *OverlayResolver) TextRecord(domain string) ([]string, error) {
+	o.mu.RLock()
+	name := canonicalName(domain)
+	nx := o.nxdomain[name]
+	txt, ok := o.txt[name]
+	o.mu.RUnlock()
+	if nx {
+		return nil, notFound(domain)
+	}
+	if ok {
+		return append([]string(nil), txt...), nil
+	}
+	return o.r.TextRecord(domain)
+}
+
+func (o *OverlayResolver) ARecord(domain string) ([]net.IP, error) {
+	o.mu.RLock()
+	name := canonicalName(domain)
+	nx := o.nxdomain[name]
+	ips, ok := o.a[name]
+	o.mu.RUnlock()
+	if nx {
+		return nil, notFound(domain)
+	}
+	if ok {
+		return append([]net.IP(nil), ips...), nil
+	}
+	return o.r.ARecord(domain)
+}
+
+func (o *OverlayResolver) MXRecord(domain string) ([]*net.MX, error) {
+	o.mu.RLock()
+	name := canonicalName(domain)
+	nx := o.nxdomain[name]
+	mxs, ok := o.mx[name]
+	o.mu.RUnlock()
+	if nx {
+		return nil, notFound(domain)
+	}
+	if ok {
+		return append([]*net.MX(nil), mxs...), nil
+	}
+	return o.r.MXRecord(domain)
+}
+
+// TextStrings returns the overridden TXT records of domain split the way
+// they would have to be published, or the strings of the underlying
+// resolver.
+func (o *OverlayResolver) TextStrings(domain string) ([][]string, error) {
+	o.mu.RLock()
+	name := canonicalName(domain)
+	nx := o.nxdomain[name]
+	txt, ok := o.txt[name]
+	o.mu.RUnlock()
+	if nx {
+		return nil, notFound(domain)
+	}
+	if ok {
+		var strs [][]string
+		for _, v := range txt {
+			strs = append(strs, splitTXT(v))
+		}
+		return strs, nil
+	}
+	return textStrings(o.r, domain)
+}
+
+func (o *OverlayResolver) SPFTypeRecord(domain string) ([]string, error) {
+	o.mu.RLock()
+	nx := o.nxdomain[canonicalName(domain)]
+	o.mu.RUnlock()
+	if nx {
+		return nil, notFound(domain)
+	}
+	return spfTypeRecord(o.r, domain)
+}
+
+func (o *OverlayResolver) NSRecord(domain string) ([]*net.NS, error) {
+	o.mu.RLock()
+	nx := o.nxdomain[canonicalName(domain)]
+	o.mu.RUnlock()
+	if nx {
+		return nil, notFound(domain)
+	}
+	return nsRecord(o.r, domain)
+}
+
+// TTL reports the TTL of the underlying resolver for names without any
+// override.
+func (o *OverlayResolver) TTL(domain string, rtype string) (uint32, bool) {
+	o.mu.RLock()
+	name := canonicalName(domain)
+	_, txt := o.txt[name]
+	_, a := o.a[name]
+	_, mx := o.mx[name]
+	nx := o.nxdomain[name]
+	o.mu.RUnlock()
+	if txt || a || mx || nx {
+		return 0, false
+	}
+	if t, ok := o.r.(ttlResolver); ok {
+		return t.TTL(domain, rtype)
+	}
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverlayResolver(t *testing.T) {
+	o := NewOverlayResolver(loadTestZone(t))
+	o.SetTXT("test.com", "v=spf1 ip4:203.0.113.0/24 include:_spf.test.com -all")
+	spf, err := New("test.com", o)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	TestTable := []struct {
+		ip     string
+		result []string
+	}{
+		{"203.0.113.9", []string{"ip4:203.0.113.0/24"}},
+		{"172.16.0.1", []string{"include:_spf.test.com", "ip4:172.16.0.0/16"}},
+		{"192.168.1.1", []string{}},
+	}
+	for _, testCase := range TestTable {
+		m, err := spf.Match(net.ParseIP(testCase.ip))
+		if err != nil {
+			t.Errorf("matching IP should not have failed but got %q", err)
+		}
+		if !reflect.DeepEqual(m, testCase.result) {
+			t.Errorf("wrong result for %s wanted %v got %v", testCase.ip, testCase.result, m)
+		}
+	}
+
+	o.SetNXDOMAIN("_spf.test.com")
+	if _, err := New("test.com", o); !errors.Is(err, PermanentError) {
+		t.Errorf("include of NXDOMAIN should have failed got %v", err)
+	}
+	o.Reset("_spf.test.com")
+	o.SetA("mx.test.com", net.ParseIP("198.51.100.1"))
+	ips, err := o.ARecord("mx.test.com")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("198.51.100.1")) {
+		t.Errorf("A override was not served got %v %q", ips, err)
+	}
+	mx, err := o.MXRecord("test.com")
+	if err != nil || len(mx) != 1 || mx[0].Host != "mx.test.com." {
+		t.Errorf("MX query did not fall through got %v %q", mx, err)
+	}
+}
+
+func TestOverlayOptionalQueries(t *testing.T) {
+	o := NewOverlayResolver(loadTestZone(t))
+	strs, err := o.TextStrings("test.com")
+	if err != nil || !reflect.DeepEqual(strs, [][]string{{"v=spf1 a mx include:_spf.test.com ", "-all"}}) {
+		t.Errorf("TXT strings did not fall through got %q %v", strs, err)
+	}
+	long := "v=spf1 " + strings.Repeat("ip4:10.0.0.1 ", 20) + "-all"
+	o.SetTXT("_spf.test.com", long)
+	strs, err = o.TextStrings("_spf.test.com")
+	if err != nil || len(strs) != 1 || len(strs[0]) != 2 || strings.Join(strs[0], "") != long {
+		t.Errorf("overridden TXT was not split into strings got %q %v", strs, err)
+	}
+	if ttl, ok := o.TTL("mx.test.com", "A"); !ok || ttl != 300 {
+		t.Errorf("TTL did not fall through got %d %v", ttl, ok)
+	}
+	o.SetA("mx.test.com", net.ParseIP("198.51.100.1"))
+	if _, ok := o.TTL("mx.test.com", "A"); ok {
+		t.Errorf("overridden names should not report a TTL")
+	}
+
+	z := NewZoneResolver()
+	if err := z.Load(strings.NewReader(lintZone), ""); err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+	findings, err := Lint("test.com", NewOverlayResolver(z))
+	if err != nil {
+		t.Fatalf("linting should not have failed but got %q", err)
+	}
+	found := false
+	for _, f := range findings {
+		found = found || f.Code == LintSPFRecordType
+	}
+	if !found {
+		t.Errorf("SPF type record was not seen through the overlay got %+v", findings)
+	}
+}
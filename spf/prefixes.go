@@ -0,0 +1,91 @@
+package spf
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+)
+
+// prefixOf converts a network to a prefix, IPv4 networks to 4 byte
+// addresses.
+func prefixOf(n *net.IPNet) netip.Prefix {
+	ones, bits := n.Mask.Size()
+	addr, _ := netip.AddrFromSlice(n.IP)
+	if bits == 32 {
+		addr = addr.Unmap()
+	}
+	return netip.PrefixFrom(addr, ones).Masked()
+}
+
+// prefixTerm returns the ip4 or ip6 term for p with qualifier q, leaving out
+// the prefix length of single addresses.
+func prefixTerm(q Qualifier, p netip.Prefix) string {
+	term := qualifierPrefix(q) + "ip6:"
+	if p.Addr().Is4() {
+		term = qualifierPrefix(q) + "ip4:"
+	}
+	if p.IsSingleIP() {
+		return term + p.Addr().String()
+	}
+	return term + p.String()
+}
+
+// comparePrefixes orders IPv4 before IPv6, then by address and then shorter
+// prefixes first.
+func comparePrefixes(a netip.Prefix, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// mergePrefixes returns the smallest sorted set of prefixes covering the same
+// addresses: contained prefixes are dropped and sibling halves are joined.
+func mergePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		sorted = append(sorted, p.Masked())
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return comparePrefixes(sorted[i], sorted[j]) < 0
+	})
+	var merged []netip.Prefix
+	for _, p := range sorted {
+		if n := len(merged); n > 0 && merged[n-1].Bits() <= p.Bits() && merged[n-1].Contains(p.Addr()) {
+			continue
+		}
+		merged = append(merged, p)
+		for n := len(merged); n >= 2; n = len(merged) {
+			a, b := merged[n-2], merged[n-1]
+			if a.Bits() != b.Bits() || a.Bits() == 0 {
+				break
+			}
+			parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+			if parent.Addr() != a.Addr() || !parent.Contains(b.Addr()) {
+				break
+			}
+			merged = append(merged[:n-2], parent)
+		}
+	}
+	return merged
+}
+
+// prefixDifference returns the prefixes covering the addresses of a that are
+// not in b.
+func prefixDifference(a []netip.Prefix, b []netip.Prefix) (diff []netip.Prefix) {
+	var run []addrInterval
+	for _, r := range elementaryIntervals(append(append([]netip.Prefix(nil), a...), b...)) {
+		if !coveredBy(a, netip.PrefixFrom(r.From, r.From.BitLen())) || coveredBy(b, netip.PrefixFrom(r.From, r.From.BitLen())) {
+			continue
+		}
+		if n := len(run); n > 0 && run[n-1].To.Next() == r.From {
+			run[n-1].To = r.To
+			continue
+		}
+		run = append(run, r)
+	}
+	for _, r := range run {
+		diff = append(diff, r.Prefixes()...)
+	}
+	return
+}
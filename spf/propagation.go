@@ -0,0 +1,222 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// AuthoritativeClient asks a single nameserver directly, without recursion.
+// Both queries fail unless the server answers authoritatively; a name that
+// does not exist gives no records and no error. The dnsclient module has an
+// implementation.
+type AuthoritativeClient interface {
+	// TXT returns the TXT records server holds for domain, the strings of
+	// each record joined.
+	TXT(server net.IP, domain string) ([]string, error)
+	// Serial returns the serial of the SOA record server holds for zone.
+	Serial(server net.IP, zone string) (uint32, error)
+}
+
+// ServerAnswer is what a single authoritative server returned for a domain.
+type ServerAnswer struct {
+	Nameserver string
+	Address    net.IP
+	Records    []string
+	Serial     uint32
+	Lame       bool
+	Error      string
+	Verdict    Qualifier
+	Match      []string
+}
+
+// DomainPropagation compares the answers of every authoritative server of
+// the zone Domain belongs to.
+type DomainPropagation struct {
+	Domain     string
+	Zone       string
+	Answers    []ServerAnswer
+	Error      string
+	Consistent bool
+}
+
+type PropagationReport struct {
+	Domain     string
+	IP         net.IP
+	Domains    []DomainPropagation
+	Consistent bool
+}
+
+// CheckPropagation asks every authoritative nameserver of domain, and of
+// each domain reached through its include and redirect terms, for the SPF
+// record directly through client. Every variant is parsed and evaluated against ip so that
+// diverging verdicts show up next to diverging record texts and serials.
+// Nameservers are found and nested mechanisms are resolved through res,
+// which has to be able to list nameservers.
+//
+// When the nameservers or the zone serial of a domain cannot be found the
+// first such failure is returned together with the report of every domain,
+// the failing ones carrying the error as well.
+func CheckPropagation(domain string, ip net.IP, res resolver, client AuthoritativeClient) (report PropagationReport, errRtn error) {
+	if _, ok := res.(nsResolver); !ok {
+		errRtn = fmt.Errorf("%w - resolver cannot list nameservers", NotSupported)
+		return
+	}
+	report = PropagationReport{Domain: domain, IP: ip, Consistent: true}
+	domains := []string{domain}
+	if spf, err := New(domain, res); err == nil {
+		seen := map[string]bool{canonicalName(domain): true}
+		spf.walk(func(path []string, m Mechanism) {
+			var d string
+			switch v := m.(type) {
+			case Include:
+				d = v.Domain
+			case Redirect:
+				d = v.Domain
+			default:
+				return
+			}
+			if !seen[canonicalName(d)] {
+				seen[canonicalName(d)] = true
+				domains = append(domains, d)
+			}
+		})
+	}
+	for _, d := range domains {
+		p, err := checkDomainPropagation(client, d, ip, res)
+		if err != nil && errRtn == nil {
+			errRtn = err
+		}
+		if !p.Consistent {
+			report.Consistent = false
+		}
+		report.Domains = append(report.Domains, p)
+	}
+	return
+}
+
+func checkDomainPropagation(client AuthoritativeClient, domain string, ip net.IP, res resolver) (p DomainPropagation, errRtn error) {
+	p.Domain = domain
+	zone, nameservers, err := findZone(domain, res)
+	if err != nil {
+		p.Error = err.Error()
+		errRtn = err
+		return
+	}
+	p.Zone = zone
+	for _, ns := range nameservers {
+		ips, err := res.ARecord(ns.Host)
+		if err != nil || len(ips) == 0 {
+			p.Answers = append(p.Answers, ServerAnswer{
+				Nameserver: ns.Host,
+				Lame:       true,
+				Error:      fmt.Sprintf("%s - no address for nameserver", DNSResolutionError),
+			})
+			continue
+		}
+		for _, v := range ips {
+			a := askAuthoritative(client, v, domain)
+			a.Nameserver = ns.Host
+			if !a.Lame && a.Error == "" {
+				a.evaluate(domain, ip, res)
+			}
+			if !a.Lame {
+				if err := a.askSerial(client, v, zone); err != nil && errRtn == nil {
+					errRtn = err
+				}
+			}
+			p.Answers = append(p.Answers, a)
+		}
+	}
+	p.Consistent = consistentAnswers(p.Answers)
+	return
+}
+
+// findZone walks up from domain to the closest name with NS records.
+func findZone(domain string, res resolver) (string, []*net.NS, error) {
+	for name := canonicalName(domain); name != ""; name = parentName(name) {
+		ns, err := nsRecord(res, name)
+		if err == nil && len(ns) > 0 {
+			return name, ns, nil
+		}
+		if errors.Is(err, NotSupported) {
+			return "", nil, err
+		}
+	}
+	return "", nil, fmt.Errorf("%w - no nameservers found for %s", DNSResolutionError, domain)
+}
+
+func askAuthoritative(client AuthoritativeClient, server net.IP, domain string) ServerAnswer {
+	a := ServerAnswer{Address: server, Verdict: Neutral}
+	txt, err := client.TXT(server, domain)
+	if err != nil {
+		a.Lame = true
+		a.Error = err.Error()
+		return a
+	}
+	for _, v := range txt {
+		if strings.HasPrefix(v, "v=spf1") {
+			a.Records = append(a.Records, v)
+		}
+	}
+	sort.Strings(a.Records)
+	return a
+}
+
+// askSerial sets the serial of zone the server holds. A failure is returned
+// and kept in the answer unless it already holds an error.
+func (a *ServerAnswer) askSerial(client AuthoritativeClient, server net.IP, zone string) error {
+	serial, err := client.Serial(server, zone)
+	if err == nil {
+		a.Serial = serial
+		return nil
+	}
+	err = fmt.Errorf("%w - serial of %s from %s: %w", DNSResolutionError, zone, server, err)
+	if a.Error == "" {
+		a.Error = err.Error()
+	}
+	return err
+}
+
+func (a *ServerAnswer) evaluate(domain string, ip net.IP, res resolver) {
+	switch len(a.Records) {
+	case 0:
+		a.Error = fmt.Sprintf("%s @ %s", NoSPFRecordPublished, domain)
+		return
+	case 1:
+	default:
+		a.Error = fmt.Sprintf("%s - multiple spf records", WrongFormat)
+		return
+	}
+	spf := SPF{Record: a.Records[0], Domain: domain, r: res}
+	if err := spf.Parse(); err != nil {
+		a.Error = err.Error()
+		return
+	}
+	q, m, err := spf.Verdict(ip)
+	if err != nil {
+		a.Error = err.Error()
+		return
+	}
+	a.Verdict = q
+	a.Match = m
+}
+
+func consistentAnswers(answers []ServerAnswer) bool {
+	var first *ServerAnswer
+	for i, a := range answers {
+		if a.Lame {
+			return false
+		}
+		if first == nil {
+			first = &answers[i]
+			continue
+		}
+		if a.Serial != first.Serial || strings.Join(a.Records, "\n") != strings.Join(first.Records, "\n") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+type authoritativeServer struct {
+	records       map[string]string
+	serial        uint32
+	authoritative bool
+	noSOA         bool
+}
+
+// fakeAuthoritative answers as the authoritative servers keyed by address.
+type fakeAuthoritative map[string]authoritativeServer
+
+func (f fakeAuthoritative) server(ip net.IP, domain string) (authoritativeServer, error) {
+	s, ok := f[ip.String()]
+	if !ok {
+		return s, fmt.Errorf("no server at %s", ip)
+	}
+	if !s.authoritative {
+		return s, fmt.Errorf("server is not authoritative for %s", domain)
+	}
+	return s, nil
+}
+
+func (f fakeAuthoritative) TXT(ip net.IP, domain string) ([]string, error) {
+	s, err := f.server(ip, domain)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := s.records[domain]; ok {
+		return []string{v}, nil
+	}
+	return nil, nil
+}
+
+func (f fakeAuthoritative) Serial(ip net.IP, zone string) (uint32, error) {
+	s, err := f.server(ip, zone)
+	if err != nil {
+		return 0, err
+	}
+	if s.noSOA {
+		return 0, fmt.Errorf("no SOA record for %s", zone)
+	}
+	return s.serial, nil
+}
+
+func TestCheckPropagation(t *testing.T) {
+	include := "v=spf1 ip4:172.16.0.0/16 -all"
+	client := fakeAuthoritative{
+		"127.0.0.1": {
+			records: map[string]string{
+				"test.com":      "v=spf1 include:_spf.test.com -all",
+				"_spf.test.com": include,
+			},
+			serial:        2,
+			authoritative: true,
+		},
+		"127.0.0.2": {
+			records: map[string]string{
+				"test.com":      "v=spf1 ip4:10.0.0.0/8 include:_spf.test.com -all",
+				"_spf.test.com": include,
+			},
+			serial:        3,
+			authoritative: true,
+		},
+		"127.0.0.3": {},
+	}
+
+	z := NewZoneResolver()
+	err := z.Load(strings.NewReader(`
+$ORIGIN test.com.
+@    NS  ns1
+@    NS  ns2
+@    NS  ns3
+@    TXT "v=spf1 include:_spf.test.com -all"
+_spf TXT "v=spf1 ip4:172.16.0.0/16 -all"
+ns1  A   127.0.0.1
+ns2  A   127.0.0.2
+ns3  A   127.0.0.3
+`), "")
+	if err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+
+	report, err := CheckPropagation("test.com", net.ParseIP("10.1.1.1"), z, client)
+	if err != nil {
+		t.Fatalf("propagation check should not have failed but got %q", err)
+	}
+	if report.Consistent || len(report.Domains) != 2 {
+		t.Fatalf("expected inconsistent report over 2 domains got %+v", report)
+	}
+	apex := report.Domains[0]
+	if apex.Zone != "test.com" || len(apex.Answers) != 3 {
+		t.Fatalf("wrong apex result %+v", apex)
+	}
+	TestTable := []struct {
+		serial  uint32
+		lame    bool
+		verdict Qualifier
+	}{
+		{2, false, Fail},
+		{3, false, Pass},
+		{0, true, Neutral},
+	}
+	for i, testCase := range TestTable {
+		a := apex.Answers[i]
+		if a.Serial != testCase.serial || a.Lame != testCase.lame || a.Verdict != testCase.verdict {
+			t.Errorf("wrong answer from %s wanted %+v got %+v", a.Nameserver, testCase, a)
+		}
+	}
+	sub := report.Domains[1]
+	if sub.Domain != "_spf.test.com" || sub.Zone != "test.com" {
+		t.Errorf("include domain was not checked got %+v", sub)
+	}
+	if sub.Answers[0].Records[0] != include || sub.Answers[1].Records[0] != include {
+		t.Errorf("wrong include records %+v", sub.Answers)
+	}
+}
+
+func TestCheckPropagationErrors(t *testing.T) {
+	client := fakeAuthoritative{
+		"127.0.0.1": {
+			records:       map[string]string{"test.com": "v=spf1 ip4:10.0.0.0/8 -all"},
+			authoritative: true,
+			noSOA:         true,
+		},
+	}
+
+	if _, err := CheckPropagation("test.com", net.ParseIP("10.1.1.1"), MockAResolver{}, client); !errors.Is(err, NotSupported) {
+		t.Errorf("resolver without NS lookups should have been rejected got %v", err)
+	}
+
+	z := NewZoneResolver()
+	err := z.Load(strings.NewReader(`
+$ORIGIN test.com.
+@    TXT "v=spf1 ip4:10.0.0.0/8 -all"
+`), "")
+	if err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+	report, err := CheckPropagation("test.com", net.ParseIP("10.1.1.1"), z, client)
+	if !errors.Is(err, DNSResolutionError) || len(report.Domains) != 1 || report.Domains[0].Error == "" {
+		t.Errorf("missing nameservers should have been reported got %v %+v", err, report)
+	}
+
+	err = z.Load(strings.NewReader(`
+$ORIGIN test.com.
+@    NS  ns1
+ns1  A   127.0.0.1
+`), "")
+	if err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+	report, err = CheckPropagation("test.com", net.ParseIP("10.1.1.1"), z, client)
+	if !errors.Is(err, DNSResolutionError) {
+		t.Fatalf("missing serial should have been reported got %v", err)
+	}
+	a := report.Domains[0].Answers[0]
+	if a.Error == "" || a.Verdict != Pass {
+		t.Errorf("answer without serial should still be evaluated got %+v", a)
+	}
+}
@@ -0,0 +1,266 @@
+package spf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var NotRecorded = errors.New("query was not recorded")
+
+type RecordedMX struct {
+	Host string `json:"host"`
+	Pref uint16 `json:"pref"`
+}
+
+// RecordedQuery is a single DNS question together with the answer that was
+// received for it. TXT holds the records of TXT and SPF queries, Strings the
+// character strings of each TXT record when they were asked for. A failed
+// query keeps its error text and whether it was a temporary failure, a
+// timeout or a name that does not exist.
+type RecordedQuery struct {
+	Time      time.Time    `json:"time"`
+	Domain    string       `json:"domain"`
+	Type      string       `json:"type"`
+	TTL       uint32       `json:"ttl,omitempty"`
+	TXT       []string     `json:"txt,omitempty"`
+	Strings   [][]string   `json:"strings,omitempty"`
+	IPs       []string     `json:"ips,omitempty"`
+	MX        []RecordedMX `json:"mx,omitempty"`
+	NS        []string     `json:"ns,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	NotFound  bool         `json:"not_found,omitempty"`
+	Temporary bool         `json:"temporary,omitempty"`
+	Timeout   bool         `json:"timeout,omitempty"`
+}
+
+// RecordingResolver passes every query to the wrapped resolver and keeps the
+// answers so they can be saved and replayed later with ReplayResolver.
+// TTLs are only recorded when the wrapped resolver reports them, as
+// ZoneResolver does. The default resolver does not, so answers recorded
+// through it have no TTL and their replay reports none either.
+type RecordingResolver struct {
+	r       resolver
+	mu      sync.Mutex
+	queries []RecordedQuery
+}
+
+func NewRecordingResolver(res resolver) *RecordingResolver {
+	return &RecordingResolver{r: res}
+}
+
+func (r *RecordingResolver) record(q RecordedQuery, err error) {
+	q.Time = time.Now().UTC()
+	if err != nil {
+		q.Error = err.Error()
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			q.NotFound, q.Timeout = dnsErr.IsNotFound, dnsErr.IsTimeout
+		}
+		q.Temporary = isTemporary(err)
+	}
+	if t, ok := r.r.(ttlResolver); ok && err == nil {
+		q.TTL, _ = t.TTL(q.Domain, q.Type)
+	}
+	r.mu.Lock()
+	r.queries = append(r.queries, q)
+	r.mu.Unlock()
+}
+
+func (r *RecordingResolver) TextRecord(domain string) ([]string, error) {
+	txt, err := r.r.TextRecord(domain)
+	r.record(RecordedQuery{Domain: domain, Type: "TXT", TXT: txt}, err)
+	return txt, err
+}
+
+func (r *RecordingResolver) TextStrings(domain string) ([][]string, error) {
+	txt, err := textStrings(r.r, domain)
+	q := RecordedQuery{Domain: domain, Type: "TXT", Strings: txt}
+	for _, v := range txt {
+		q.TXT = append(q.TXT, strings.Join(v, ""))
+	}
+	r.record(q, err)
+	return txt, err
+}
+
+func (r *RecordingResolver) SPFTypeRecord(domain string) ([]string, error) {
+	spf, err := spfTypeRecord(r.r, domain)
+	r.record(RecordedQuery{Domain: domain, Type: "SPF", TXT: spf}, err)
+	return spf, err
+}
+
+func (r *RecordingResolver) ARecord(domain string) ([]net.IP, error) {
+	ips, err := r.r.ARecord(domain)
+	q := RecordedQuery{Domain: domain, Type: "A"}
+	for _, ip := range ips {
+		q.IPs = append(q.IPs, ip.String())
+	}
+	r.record(q, err)
+	return ips, err
+}
+
+func (r *RecordingResolver) MXRecord(domain string) ([]*net.MX, error) {
+	mxs, err := r.r.MXRecord(domain)
+	q := RecordedQuery{Domain: domain, Type: "MX"}
+	for _, mx := range mxs {
+		q.MX = append(q.MX, RecordedMX{Host: mx.Host, Pref: mx.Pref})
+	}
+	r.record(q, err)
+	return mxs, err
+}
+
+func (r *RecordingResolver) NSRecord(domain string) ([]*net.NS, error) {
+	ns, err := nsRecord(r.r, domain)
+	q := RecordedQuery{Domain: domain, Type: "NS"}
+	for _, v := range ns {
+		q.NS = append(q.NS, v.Host)
+	}
+	r.record(q, err)
+	return ns, err
+}
+
+// Queries returns a copy of everything recorded so far in query order.
+func (r *RecordingResolver) Queries() []RecordedQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedQuery(nil), r.queries...)
+}
+
+func (r *RecordingResolver) Save(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r.Queries())
+}
+
+func (r *RecordingResolver) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReplayResolver answers queries from a recording. Repeated queries get the
+// recorded answers in order, the last one being reused once they run out.
+// Queries that were never recorded fail with NotRecorded.
+type ReplayResolver struct {
+	mu      sync.Mutex
+	answers map[string][]RecordedQuery
+}
+
+func NewReplayResolver(r io.Reader) (*ReplayResolver, error) {
+	var queries []RecordedQuery
+	if err := json.NewDecoder(r).Decode(&queries); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	rr := &ReplayResolver{answers: make(map[string][]RecordedQuery)}
+	for _, q := range queries {
+		k := replayKey(q.Domain, q.Type)
+		rr.answers[k] = append(rr.answers[k], q)
+	}
+	return rr, nil
+}
+
+func NewReplayResolverFromFile(path string) (*ReplayResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayResolver(f)
+}
+
+func replayKey(domain string, rtype string) string {
+	return rtype + " " + canonicalName(domain)
+}
+
+func (r *ReplayResolver) next(domain string, rtype string, consume bool) (RecordedQuery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := replayKey(domain, rtype)
+	answers := r.answers[k]
+	if len(answers) == 0 {
+		return RecordedQuery{}, fmt.Errorf("%w - %s %s", NotRecorded, rtype, domain)
+	}
+	q := answers[0]
+	if consume && len(answers) > 1 {
+		r.answers[k] = answers[1:]
+	}
+	if q.NotFound {
+		return q, notFound(domain)
+	}
+	if q.Error != "" {
+		return q, &net.DNSError{Err: q.Error, Name: domain, IsTemporary: q.Temporary, IsTimeout: q.Timeout}
+	}
+	return q, nil
+}
+
+func (r *ReplayResolver) TextRecord(domain string) ([]string, error) {
+	q, err := r.next(domain, "TXT", true)
+	return q.TXT, err
+}
+
+// TextStrings replays the strings of a recorded TXT answer. Answers recorded
+// through TextRecord have no strings, each record is then a single string.
+func (r *ReplayResolver) TextStrings(domain string) ([][]string, error) {
+	q, err := r.next(domain, "TXT", true)
+	if q.Strings != nil {
+		return q.Strings, err
+	}
+	var txt [][]string
+	for _, v := range q.TXT {
+		txt = append(txt, []string{v})
+	}
+	return txt, err
+}
+
+func (r *ReplayResolver) SPFTypeRecord(domain string) ([]string, error) {
+	q, err := r.next(domain, "SPF", true)
+	return q.TXT, err
+}
+
+func (r *ReplayResolver) ARecord(domain string) ([]net.IP, error) {
+	q, err := r.next(domain, "A", true)
+	var ips []net.IP
+	for _, v := range q.IPs {
+		ips = append(ips, net.ParseIP(v))
+	}
+	return ips, err
+}
+
+func (r *ReplayResolver) MXRecord(domain string) ([]*net.MX, error) {
+	q, err := r.next(domain, "MX", true)
+	var mxs []*net.MX
+	for _, v := range q.MX {
+		mxs = append(mxs, &net.MX{Host: v.Host, Pref: v.Pref})
+	}
+	return mxs, err
+}
+
+func (r *ReplayResolver) NSRecord(domain string) ([]*net.NS, error) {
+	q, err := r.next(domain, "NS", true)
+	var ns []*net.NS
+	for _, v := range q.NS {
+		ns = append(ns, &net.NS{Host: v})
+	}
+	return ns, err
+}
+
+// TTL reports the recorded TTL of the answer the next query would get.
+func (r *ReplayResolver) TTL(domain string, rtype string) (uint32, bool) {
+	q, err := r.next(domain, rtype, false)
+	if err != nil || q.TTL == 0 {
+		return 0, false
+	}
+	return q.TTL, true
+}
@@ -0,0 +1,168 @@
+package spf
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	recorder := NewRecordingResolver(loadTestZone(t))
+	original, err := New("test.com", recorder)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	if _, err := recorder.TextRecord("missing.test.com"); err == nil {
+		t.Fatalf("lookup of missing name should have failed")
+	}
+	queries := recorder.Queries()
+	if len(queries) != 6 {
+		t.Errorf("expected 6 recorded queries got %d: %v", len(queries), queries)
+	}
+	if queries[0].Type != "TXT" || queries[0].TTL != 3600 || queries[0].Time.IsZero() {
+		t.Errorf("first query was not recorded properly got %+v", queries[0])
+	}
+	var buf bytes.Buffer
+	if err := recorder.Save(&buf); err != nil {
+		t.Fatalf("saving should not have failed but got %q", err)
+	}
+
+	replay, err := NewReplayResolver(&buf)
+	if err != nil {
+		t.Fatalf("loading recording should not have failed but got %q", err)
+	}
+	replayed, err := New("test.com", replay)
+	if err != nil {
+		t.Fatalf("replaying SPF should not have failed but got %q", err)
+	}
+	if replayed.Record != original.Record {
+		t.Errorf("wrong record wanted %q got %q", original.Record, replayed.Record)
+	}
+	for _, ip := range []string{"192.168.1.1", "10.5.5.1", "172.16.1.1", "8.8.8.8"} {
+		want, _ := original.Match(net.ParseIP(ip))
+		got, _ := replayed.Match(net.ParseIP(ip))
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("replay of %s differs wanted %v got %v", ip, want, got)
+		}
+	}
+	var dnsErr *net.DNSError
+	if _, err := replay.TextRecord("missing.test.com"); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("recorded NXDOMAIN was not replayed got %v", err)
+	}
+	if _, err := replay.ARecord("other.test.com"); !errors.Is(err, NotRecorded) {
+		t.Errorf("unrecorded query should fail with NotRecorded got %v", err)
+	}
+}
+
+type failingResolver struct {
+	MockResolver
+}
+
+func (failingResolver) TextRecord(domain string) ([]string, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: domain, IsTemporary: true}
+}
+
+func (failingResolver) ARecord(domain string) ([]net.IP, error) {
+	return nil, &net.DNSError{Err: "i/o timeout", Name: domain, IsTimeout: true, IsTemporary: true}
+}
+
+func (failingResolver) MXRecord(domain string) ([]*net.MX, error) {
+	return nil, &net.DNSError{Err: "refused", Name: domain}
+}
+
+func TestReplayErrors(t *testing.T) {
+	recorder := NewRecordingResolver(failingResolver{})
+	recorder.TextRecord("test.com")
+	recorder.ARecord("test.com")
+	recorder.MXRecord("test.com")
+	var buf bytes.Buffer
+	if err := recorder.Save(&buf); err != nil {
+		t.Fatalf("saving should not have failed but got %q", err)
+	}
+	replay, err := NewReplayResolver(&buf)
+	if err != nil {
+		t.Fatalf("loading recording should not have failed but got %q", err)
+	}
+
+	_, txtErr := replay.TextRecord("test.com")
+	_, aErr := replay.ARecord("test.com")
+	_, mxErr := replay.MXRecord("test.com")
+	TestTable := []struct {
+		rtype     string
+		err       error
+		temporary bool
+		timeout   bool
+	}{
+		{"TXT", txtErr, true, false},
+		{"A", aErr, true, true},
+		{"MX", mxErr, false, false},
+	}
+	for _, test := range TestTable {
+		var dnsErr *net.DNSError
+		if !errors.As(test.err, &dnsErr) {
+			t.Fatalf("%s: replayed error should be a DNS error got %v", test.rtype, test.err)
+		}
+		if dnsErr.IsTemporary != test.temporary || dnsErr.IsTimeout != test.timeout {
+			t.Errorf("%s: wrong replayed flags wanted temporary %v timeout %v got %+v",
+				test.rtype, test.temporary, test.timeout, dnsErr)
+		}
+	}
+	if _, err := New("test.com", replay); !errors.Is(err, TemporaryError) {
+		t.Errorf("replayed temporary failure should be a temperror got %v", err)
+	}
+}
+
+func TestRecordTXTVariants(t *testing.T) {
+	z := NewZoneResolver()
+	err := z.Load(strings.NewReader(`
+$ORIGIN test.com.
+@    TXT ( "v=spf1 a mx include:_spf.test.com " "-all" )
+@    SPF "v=spf1 -all"
+`), "")
+	if err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+	recorder := NewRecordingResolver(z)
+	want, err := recorder.TextStrings("test.com")
+	if err != nil {
+		t.Fatalf("TextStrings should not have failed but got %q", err)
+	}
+	if _, err := recorder.SPFTypeRecord("test.com"); err != nil {
+		t.Fatalf("SPFTypeRecord should not have failed but got %q", err)
+	}
+	var buf bytes.Buffer
+	recorder.Save(&buf)
+	replay, err := NewReplayResolver(&buf)
+	if err != nil {
+		t.Fatalf("loading recording should not have failed but got %q", err)
+	}
+	if got, err := replay.TextStrings("test.com"); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong replayed strings wanted %q got %q %v", want, got, err)
+	}
+	if txt, err := replay.TextRecord("test.com"); err != nil || txt[0] != "v=spf1 a mx include:_spf.test.com -all" {
+		t.Errorf("TXT strings should replay as a joined record got %q %v", txt, err)
+	}
+	if spf, err := replay.SPFTypeRecord("test.com"); err != nil || !reflect.DeepEqual(spf, []string{"v=spf1 -all"}) {
+		t.Errorf("recorded SPF type answer was not replayed got %q %v", spf, err)
+	}
+}
+
+func TestRecordWithoutTTL(t *testing.T) {
+	recorder := NewRecordingResolver(MockResolver{txtDomains: txtDomainPair{"test.com": {"v=spf1 -all"}}})
+	recorder.TextRecord("test.com")
+	if q := recorder.Queries()[0]; q.TTL != 0 {
+		t.Errorf("resolver without TTLs should record none got %d", q.TTL)
+	}
+	var buf bytes.Buffer
+	recorder.Save(&buf)
+	replay, err := NewReplayResolver(&buf)
+	if err != nil {
+		t.Fatalf("loading recording should not have failed but got %q", err)
+	}
+	if _, ok := replay.TTL("test.com", "TXT"); ok {
+		t.Errorf("replay of an answer without TTL should not report one")
+	}
+}
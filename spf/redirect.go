@@ -0,0 +1,51 @@
+package spf
+
+import (
+	"net"
+)
+
+// Redirect is the redirect= modifier. It is only consulted when no mechanism
+// of the record matched and the record has no all mechanism.
+type Redirect struct {
+	Domain string
+	Record string
+	r      resolver
+	spf    SPF
+}
+
+func NewRedirect(record string, res resolver, opts ...Option) (Redirect, error) {
+	d, err := matchRedirect(record)
+	if err != nil {
+		return Redirect{}, err
+	}
+	spf, err := New(d, res, opts...)
+	if err != nil {
+		return Redirect{}, err
+	}
+	r := Redirect{
+		Record: record,
+		Domain: d,
+		r:      res,
+		spf:    spf,
+	}
+	return r, nil
+}
+
+func (r Redirect) Match(ip net.IP) ([]string, error) {
+	_, m, err := r.spf.evaluate(ip, false)
+	if err != nil {
+		return []string{}, err
+	}
+	if len(m) > 0 {
+		m = append([]string{r.Record}, m...)
+	}
+	return m, nil
+}
+
+func (r Redirect) String() string {
+	return "redirect=" + domainSpec(r.Domain)
+}
+
+func (r Redirect) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
@@ -2,16 +2,77 @@ package spf
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"time"
 )
 
-type resolver interface {
+// NotSupported is returned by resolvers wrapping another one for optional
+// queries the wrapped resolver cannot answer.
+var NotSupported = errors.New("query not supported by the resolver")
+
+// Resolver is what every function of the package building records needs
+// for its lookups. Resolvers can implement further optional queries, such
+// as NS lookups or TTLs, that some features use when available.
+type Resolver interface {
 	TextRecord(string) ([]string, error)
 	ARecord(string) ([]net.IP, error)
 	MXRecord(string) ([]*net.MX, error)
 }
 
+type resolver = Resolver
+
+// nsResolver is implemented by resolvers that can list the nameservers of a
+// domain.
+type nsResolver interface {
+	NSRecord(string) ([]*net.NS, error)
+}
+
+// txtStringsResolver is implemented by resolvers that can return TXT records
+// as the individual character strings they were published as.
+type txtStringsResolver interface {
+	TextStrings(domain string) ([][]string, error)
+}
+
+// spfTypeResolver is implemented by resolvers that can look up the deprecated
+// SPF resource record type 99.
+type spfTypeResolver interface {
+	SPFTypeRecord(domain string) ([]string, error)
+}
+
+// ttlResolver is implemented by resolvers that know for how long an answer
+// may be cached. rtype is the record type name, e.g. "TXT".
+type ttlResolver interface {
+	TTL(domain string, rtype string) (uint32, bool)
+}
+
+// nsRecord asks r for the nameservers of domain when it can list them.
+func nsRecord(r resolver, domain string) ([]*net.NS, error) {
+	if v, ok := r.(nsResolver); ok {
+		return v.NSRecord(domain)
+	}
+	return nil, fmt.Errorf("%w - NS of %s", NotSupported, domain)
+}
+
+// textStrings asks r for the TXT records of domain split into their strings
+// when it can return them.
+func textStrings(r resolver, domain string) ([][]string, error) {
+	if v, ok := r.(txtStringsResolver); ok {
+		return v.TextStrings(domain)
+	}
+	return nil, fmt.Errorf("%w - TXT strings of %s", NotSupported, domain)
+}
+
+// spfTypeRecord asks r for the SPF type records of domain when it can look
+// them up.
+func spfTypeRecord(r resolver, domain string) ([]string, error) {
+	if v, ok := r.(spfTypeResolver); ok {
+		return v.SPFTypeRecord(domain)
+	}
+	return nil, fmt.Errorf("%w - SPF type of %s", NotSupported, domain)
+}
+
 type defaultResolver struct {
 	count    int
 	resolver *net.Resolver
@@ -66,3 +127,11 @@ func (r defaultResolver) MXRecord(domain string) ([]*net.MX, error) {
 	}
 	return net.LookupMX(domain)
 }
+
+func (r defaultResolver) NSRecord(domain string) ([]*net.NS, error) {
+	r.count = r.count + 1
+	if r.resolver != nil {
+		return r.resolver.LookupNS(context.Background(), domain)
+	}
+	return net.LookupNS(domain)
+}
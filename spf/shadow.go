@@ -0,0 +1,121 @@
+package spf
+
+import (
+	"net"
+)
+
+// Shadow is a mechanism that can never decide a result because every address
+// it matches is already matched by an earlier term of the same record.
+type Shadow struct {
+	Domain     string
+	Term       string
+	ShadowedBy []string
+}
+
+// Shadowed returns the dead mechanisms of the record and of every record it
+// includes or redirects to. A network counts as covered when a single network
+// of an earlier term contains it; a mechanism without any network, like an a
+// whose lookup came back empty, is never reported.
+func (spf *SPF) Shadowed() []Shadow {
+	shadows := shadowedIn(spf)
+	seen := map[string]bool{canonicalName(spf.Domain): true}
+	spf.walk(func(path []string, m Mechanism) {
+		var r *SPF
+		switch v := m.(type) {
+		case Include:
+			r = &v.spf
+		case Redirect:
+			r = &v.spf
+		default:
+			return
+		}
+		if !seen[canonicalName(r.Domain)] {
+			seen[canonicalName(r.Domain)] = true
+			shadows = append(shadows, shadowedIn(r)...)
+		}
+	})
+	return shadows
+}
+
+type coverage struct {
+	term     string
+	all      bool
+	networks []*net.IPNet
+}
+
+func shadowedIn(spf *SPF) (shadows []Shadow) {
+	var earlier []coverage
+	for _, m := range spf.Mechanisms {
+		c := coverageOf(m)
+		if by := shadowingTerms(earlier, c); len(by) > 0 {
+			shadows = append(shadows, Shadow{Domain: spf.Domain, Term: c.term, ShadowedBy: by})
+		}
+		earlier = append(earlier, c)
+	}
+	return
+}
+
+// coverageOf returns every network m matches. An include matches where the
+// included record gives pass; when that record fails to evaluate its
+// coverage is unknown and it is left without networks, so it shadows nothing
+// and is never reported.
+func coverageOf(m Mechanism) coverage {
+	c := coverage{term: termOf(m)}
+	switch v := m.(type) {
+	case All:
+		c.all = true
+	case Include:
+		sets, err := v.spf.Authorized()
+		if err != nil {
+			break
+		}
+		for _, p := range sets[Pass].Prefixes {
+			c.networks = append(c.networks, &net.IPNet{IP: p.Addr().AsSlice(), Mask: net.CIDRMask(p.Bits(), p.Addr().BitLen())})
+		}
+	default:
+		c.networks = networksOf(m)
+	}
+	return c
+}
+
+// shadowingTerms returns the earlier terms covering c, nil if c is not fully
+// covered.
+func shadowingTerms(earlier []coverage, c coverage) (by []string) {
+	for _, e := range earlier {
+		if e.all {
+			return []string{e.term}
+		}
+	}
+	if c.all || len(c.networks) == 0 {
+		return nil
+	}
+	used := make(map[string]bool)
+	for _, n := range c.networks {
+		found := ""
+		for _, e := range earlier {
+			for _, outer := range e.networks {
+				if containsNetwork(outer, n) {
+					found = e.term
+					break
+				}
+			}
+			if found != "" {
+				break
+			}
+		}
+		if found == "" {
+			return nil
+		}
+		if !used[found] {
+			used[found] = true
+			by = append(by, found)
+		}
+	}
+	return
+}
+
+func containsNetwork(outer *net.IPNet, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
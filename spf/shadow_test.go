@@ -0,0 +1,44 @@
+package spf
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestShadowed(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com": []string{"v=spf1 ip4:10.0.0.0/8 include:_spf.test.com ip4:10.1.0.0/16 " +
+				"a ip4:172.16.5.0/24 ip6:2001:db8::/32 ip6:2001:db8:1::/48 mx -all ip4:192.0.2.1"},
+			"_spf.test.com": []string{"v=spf1 ip4:172.16.0.0/16 ip4:172.16.1.1 -all"},
+		},
+		aDomains: aDomainPair{"test.com": {net.ParseIP("10.5.5.5")}},
+	}
+	spf, err := New("test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	want := []Shadow{
+		{"test.com", "ip4:10.1.0.0/16", []string{"ip4:10.0.0.0/8"}},
+		{"test.com", "a", []string{"ip4:10.0.0.0/8"}},
+		{"test.com", "ip4:172.16.5.0/24", []string{"include:_spf.test.com"}},
+		{"test.com", "ip6:2001:db8:1::/48", []string{"ip6:2001:db8::/32"}},
+		{"test.com", "ip4:192.0.2.1", []string{"-all"}},
+		{"_spf.test.com", "ip4:172.16.1.1", []string{"ip4:172.16.0.0/16"}},
+	}
+	if got := spf.Shadowed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong shadows wanted\n%v\ngot\n%v", want, got)
+	}
+
+	res.txtDomains["fail.test.com"] = []string{"v=spf1 include:_fail.test.com ip4:198.51.100.7 ip4:203.0.113.9 -all"}
+	res.txtDomains["_fail.test.com"] = []string{"v=spf1 -ip4:198.51.100.0/24 ip4:203.0.113.0/24 ?all"}
+	spf, err = New("fail.test.com", res)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	want = []Shadow{{"fail.test.com", "ip4:203.0.113.9", []string{"include:_fail.test.com"}}}
+	if got := spf.Shadowed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("include should only cover what it passes wanted\n%v\ngot\n%v", want, got)
+	}
+}
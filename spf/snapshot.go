@@ -0,0 +1,90 @@
+package spf
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// SnapshotDiff is the change of a single record of the tree between two
+// snapshots. Path holds the include and redirect terms leading to the
+// record, empty for the record of the domain itself. OldRecord and
+// NewRecord are only set when the text changed, one of them is empty when
+// the record was only reached in one of the snapshots. Added and Removed
+// are the addresses the record's own mechanisms started or stopped
+// matching, without the ones of the records it includes.
+type SnapshotDiff struct {
+	Path      []string
+	Domain    string
+	OldRecord string
+	NewRecord string
+	Added     []netip.Prefix
+	Removed   []netip.Prefix
+}
+
+type snapshotRecord struct {
+	path     []string
+	domain   string
+	record   string
+	prefixes []netip.Prefix
+}
+
+// DiffSnapshots compares two trees of the same domain, for example built by
+// New at different times, and returns the records that changed, in the order
+// they are evaluated.
+func DiffSnapshots(before *SPF, after *SPF) (diffs []SnapshotDiff) {
+	old, oldOrder := snapshotRecords(before)
+	current, currentOrder := snapshotRecords(after)
+	order := oldOrder
+	for _, key := range currentOrder {
+		if _, ok := old[key]; !ok {
+			order = append(order, key)
+		}
+	}
+	for _, key := range order {
+		o, inOld := old[key]
+		c, inCurrent := current[key]
+		d := SnapshotDiff{Path: c.path, Domain: c.domain}
+		if !inCurrent {
+			d.Path, d.Domain = o.path, o.domain
+		}
+		if o.record != c.record || inOld != inCurrent {
+			d.OldRecord, d.NewRecord = o.record, c.record
+		}
+		d.Added = prefixDifference(c.prefixes, o.prefixes)
+		d.Removed = prefixDifference(o.prefixes, c.prefixes)
+		if d.OldRecord != "" || d.NewRecord != "" || len(d.Added) > 0 || len(d.Removed) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	return
+}
+
+// snapshotRecords returns every record of the tree keyed by its path, and the
+// keys in evaluation order.
+func snapshotRecords(spf *SPF) (map[string]snapshotRecord, []string) {
+	records := map[string]snapshotRecord{"": {domain: spf.Domain, record: spf.Record}}
+	order := []string{""}
+	spf.walk(func(path []string, m Mechanism) {
+		key := strings.Join(path, " ")
+		r := records[key]
+		r.prefixes = append(r.prefixes, networkPrefixes(m)...)
+		records[key] = r
+
+		var inner *SPF
+		switch v := m.(type) {
+		case Include:
+			inner = &v.spf
+		case Redirect:
+			inner = &v.spf
+		default:
+			return
+		}
+		innerPath := append(path[:len(path):len(path)], termOf(m))
+		innerKey := strings.Join(innerPath, " ")
+		if _, ok := records[innerKey]; !ok {
+			records[innerKey] = snapshotRecord{path: innerPath, domain: inner.Domain, record: inner.Record}
+			order = append(order, innerKey)
+		}
+	})
+	return records, order
+}
@@ -0,0 +1,57 @@
+package spf
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":          []string{"v=spf1 ip4:10.0.0.0/24 include:_spf.provider.com -all"},
+			"_spf.provider.com": []string{"v=spf1 ip4:192.0.2.0/24 include:_net.provider.com -all"},
+			"_net.provider.com": []string{"v=spf1 ip4:198.51.100.0/24 -all"},
+		},
+	}
+	after := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":          []string{"v=spf1 ip4:10.0.0.0/24 include:_spf.provider.com -all"},
+			"_spf.provider.com": []string{"v=spf1 ip4:192.0.2.0/25 ip4:203.0.113.0/24 include:_net.provider.com include:_new.provider.com -all"},
+			"_net.provider.com": []string{"v=spf1 ip4:198.51.100.0/24 -all"},
+			"_new.provider.com": []string{"v=spf1 ip4:100.64.0.0/10 -all"},
+		},
+	}
+	old, err := New("test.com", before)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	current, err := New("test.com", after)
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+
+	if diffs := DiffSnapshots(&old, &old); len(diffs) != 0 {
+		t.Errorf("identical snapshots should not differ got %+v", diffs)
+	}
+
+	want := []SnapshotDiff{
+		{
+			Path:      []string{"include:_spf.provider.com"},
+			Domain:    "_spf.provider.com",
+			OldRecord: "v=spf1 ip4:192.0.2.0/24 include:_net.provider.com -all",
+			NewRecord: "v=spf1 ip4:192.0.2.0/25 ip4:203.0.113.0/24 include:_net.provider.com include:_new.provider.com -all",
+			Added:     []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+			Removed:   []netip.Prefix{netip.MustParsePrefix("192.0.2.128/25")},
+		},
+		{
+			Path:      []string{"include:_spf.provider.com", "include:_new.provider.com"},
+			Domain:    "_new.provider.com",
+			NewRecord: "v=spf1 ip4:100.64.0.0/10 -all",
+			Added:     []netip.Prefix{netip.MustParsePrefix("100.64.0.0/10")},
+		},
+	}
+	if got := DiffSnapshots(&old, &current); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong differences wanted\n%+v\ngot\n%+v", want, got)
+	}
+}
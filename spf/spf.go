@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 )
 
 type Qualifier int
@@ -16,93 +17,357 @@ const (
 	Neutral
 )
 
+func (q Qualifier) String() string {
+	switch q {
+	case Pass:
+		return "pass"
+	case Fail:
+		return "fail"
+	case Softfail:
+		return "softfail"
+	case Neutral:
+		return "neutral"
+	}
+	return fmt.Sprintf("Qualifier(%d)", int(q))
+}
+
+func (q Qualifier) MarshalText() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// qualifierPrefix returns the symbol of q in a record, empty for pass.
+func qualifierPrefix(q Qualifier) string {
+	switch q {
+	case Fail:
+		return "-"
+	case Softfail:
+		return "~"
+	case Neutral:
+		return "?"
+	}
+	return ""
+}
+
 var (
 	WrongFormat          = errors.New("wrong mechanism format")
 	WrongMechanism       = errors.New("wrong mechanism")
 	DNSResolutionError   = errors.New("failed to resolve domain")
 	NoSPFRecordPublished = errors.New("no spf record found under the domain")
+	PermanentError       = errors.New("permanent spf error")
+	LookupLoop           = errors.New("include or redirect loop")
+	TooManyVoidLookups   = errors.New("void dns lookup limit exceeded")
 )
 
 type Mechanism interface {
 	Match(net.IP) ([]string, error)
 }
 
+// SPF is a parsed record with every lookup it needs already resolved. Match
+// and Verdict do not modify it, so once New returned it is safe for
+// concurrent use by multiple goroutines.
 type SPF struct {
 	Record     string
 	Domain     string
 	r          resolver
 	Mechanisms []Mechanism
+	Redirect   *Redirect
+	o          Observer
+	opts       []Option
+	parallel   bool
+	catalog    *Catalog
+	// voidIncludes keeps includes of domains without a record as includes
+	// that never match, so Lint can report them instead of failing.
+	voidIncludes bool
+	// path holds the domains of the records from the top of the tree down
+	// to this one, so includes and redirects that loop are refused.
+	path []string
+	// budget counts the lookups of the whole tree while New resolves it.
+	budget *lookupBudget
+}
+
+// lookupBudget counts the terms of a tree that query DNS, and the ones that
+// came back empty, against the limits of RFC 7208 section 4.6.4. The
+// records of the tree share it while they are resolved, so a tree fails
+// with a permerror as soon as it needs too many lookups.
+type lookupBudget struct {
+	mu        sync.Mutex
+	lookups   int
+	voids     int
+	limit     int
+	voidLimit int
+}
+
+func (b *lookupBudget) lookup(term string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lookups++
+	if b.lookups > b.limit {
+		return fmt.Errorf("%w - %w - %s needs lookup %d, the limit is %d", PermanentError, TooManyLookups, term, b.lookups, b.limit)
+	}
+	return nil
+}
+
+func (b *lookupBudget) void(term string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.voids++
+	if b.voids > b.voidLimit {
+		return fmt.Errorf("%w - %w - %s is void lookup %d, the limit is %d", PermanentError, TooManyVoidLookups, term, b.voids, b.voidLimit)
+	}
+	return nil
 }
 
 func (spf *SPF) Parse() error {
+	if spf.budget == nil {
+		spf.budget = &lookupBudget{limit: MaxLookups, voidLimit: MaxVoidLookups}
+		if spf.voidIncludes {
+			spf.budget.limit, spf.budget.voidLimit = lintLookupLimit, lintLookupLimit
+		}
+	}
 	s := strings.Split(spf.Record, " ")
+	if spf.parallel {
+		return spf.parseParallel(s)
+	}
 	for _, v := range s {
-		if v == "v=spf1" {
-			continue
-		}
-		if IsAMechanism(v) {
-			m, err := NewA(v, spf.Domain, spf.r)
-			if err != nil {
-				return err
-			}
-			spf.Mechanisms = append(spf.Mechanisms, m)
-			continue
-		}
-		if isMXMechanism(v) {
-			m, err := NewMX(v, spf.Domain, spf.r)
-			if err != nil {
-				return err
-			}
-			spf.Mechanisms = append(spf.Mechanisms, m)
-			continue
+		m, err := spf.parseTerm(v)
+		if err != nil {
+			return err
 		}
-		if isALLMechanism(v) {
-			m, err := NewAll(v)
-			if err != nil {
-				return err
-			}
-			spf.Mechanisms = append(spf.Mechanisms, m)
-			continue
+		spf.add(m)
+	}
+	return nil
+}
+
+// parseParallel resolves every term at the same time but keeps them in
+// record order, so evaluation is the same as with sequential parsing. The
+// first error in record order is returned.
+func (spf *SPF) parseParallel(terms []string) error {
+	mechanisms := make([]Mechanism, len(terms))
+	errs := make([]error, len(terms))
+	var wg sync.WaitGroup
+	for i, v := range terms {
+		wg.Add(1)
+		go func(i int, v string) {
+			defer wg.Done()
+			mechanisms[i], errs[i] = spf.parseTerm(v)
+		}(i, v)
+	}
+	wg.Wait()
+	for i, m := range mechanisms {
+		if errs[i] != nil {
+			return errs[i]
 		}
-		if IsIncludeMechanism(v) {
-			m, err := NewInclude(v, spf.r)
-			if err != nil {
-				return err
-			}
-			spf.Mechanisms = append(spf.Mechanisms, m)
-			continue
+		spf.add(m)
+	}
+	return nil
+}
+
+func (spf *SPF) add(m Mechanism) {
+	switch v := m.(type) {
+	case nil:
+	case Redirect:
+		spf.Redirect = &v
+	default:
+		spf.Mechanisms = append(spf.Mechanisms, m)
+	}
+}
+
+// parseTerm builds the mechanism or modifier for a single record term and
+// counts it against the lookup budget before it is resolved.
+func (spf *SPF) parseTerm(v string) (Mechanism, error) {
+	if lookupTerms[termName(v)] {
+		if err := spf.budget.lookup(v); err != nil {
+			return nil, err
 		}
-		if isIPMechanism(v) {
-			m, err := NewIP(v)
-			if err != nil {
-				return err
-			}
-			spf.Mechanisms = append(spf.Mechanisms, m)
-			continue
+	}
+	m, err := spf.newTerm(v)
+	if err == nil && isVoid(m) {
+		err = spf.budget.void(v)
+	}
+	return m, err
+}
+
+// newTerm builds the mechanism or modifier for a single record term. Terms
+// that are not supported give a nil Mechanism.
+func (spf *SPF) newTerm(v string) (Mechanism, error) {
+	if v == "v=spf1" {
+		return nil, nil
+	}
+	if IsAMechanism(v) {
+		return NewA(v, spf.Domain, spf.r)
+	}
+	if isMXMechanism(v) {
+		return NewMX(v, spf.Domain, spf.r)
+	}
+	if isALLMechanism(v) {
+		return NewAll(v)
+	}
+	if IsIncludeMechanism(v) {
+		return NewInclude(v, spf.r, spf.childOpts(spf.budget)...)
+	}
+	if isIPMechanism(v) {
+		return NewIP(v)
+	}
+	if isRedirectModifier(v) {
+		return NewRedirect(v, spf.r, spf.childOpts(spf.budget)...)
+	}
+	return nil, nil
+}
+
+// childOpts returns the options for a record spf includes or redirects to.
+// They carry the path down to it and the lookup budget it counts against,
+// a new one when budget is nil.
+func (spf *SPF) childOpts(budget *lookupBudget) []Option {
+	return append(spf.opts[:len(spf.opts):len(spf.opts)], withTree(spf.path, budget))
+}
+
+func withTree(path []string, budget *lookupBudget) Option {
+	return func(spf *SPF) {
+		spf.path = path
+		spf.budget = budget
+	}
+}
+
+func (spf *SPF) hasAll() bool {
+	for _, v := range spf.Mechanisms {
+		if _, ok := v.(All); ok {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
 func (spf *SPF) Match(ip net.IP) (match []string, errRtn error) {
+	_, match, errRtn = spf.evaluate(ip, false)
+	if spf.o != nil {
+		spf.o.OnResult(spf.Domain, ip, match, errRtn)
+	}
+	return
+}
+
+// Verdict evaluates ip like Match but also returns the qualifier of the
+// deciding mechanism. When only all applies its qualifier and record are
+// returned, without any match the result is Neutral.
+func (spf *SPF) Verdict(ip net.IP) (q Qualifier, match []string, errRtn error) {
+	q, match, errRtn = spf.evaluate(ip, true)
+	if spf.o != nil {
+		spf.o.OnResult(spf.Domain, ip, match, errRtn)
+	}
+	return
+}
+
+// evaluate runs the mechanisms in order and returns the qualifier and chain
+// of the first one matching ip. all only ends the evaluation when withAll is
+// set, Match never reported it.
+func (spf *SPF) evaluate(ip net.IP, withAll bool) (Qualifier, []string, error) {
 	for _, v := range spf.Mechanisms {
+		if a, ok := v.(All); ok && withAll {
+			spf.notifyMechanism(a.Record, true)
+			return a.Qualifier, []string{a.Record}, nil
+		}
 		m, err := v.Match(ip)
 		if err != nil {
-			return []string{}, err
+			return Neutral, []string{}, err
+		}
+		spf.notifyMechanism(termOf(v), len(m) > 0)
+		if len(m) > 0 {
+			return qualifierOf(v), m, nil
+		}
+	}
+	if spf.Redirect != nil && !spf.hasAll() {
+		q, m, err := spf.Redirect.spf.evaluate(ip, withAll)
+		if err != nil {
+			return Neutral, []string{}, err
 		}
 		if len(m) > 0 {
-			return m, nil
+			m = append([]string{spf.Redirect.Record}, m...)
 		}
+		return q, m, nil
+	}
+	return Neutral, []string{}, nil
+}
+
+func (spf *SPF) notifyMechanism(term string, matched bool) {
+	if spf.o != nil {
+		spf.o.OnMechanismEvaluated(spf.Domain, term, matched)
 	}
-	return []string{}, nil
 }
 
-func New(domain string, res resolver) (spf SPF, errRtn error) {
+func qualifierOf(m Mechanism) Qualifier {
+	switch v := m.(type) {
+	case A:
+		return v.Qualifier
+	case MX:
+		return v.Qualifier
+	case IP:
+		return v.Qualifier
+	case Include:
+		return v.Qualifier
+	case All:
+		return v.Qualifier
+	}
+	return Pass
+}
+
+// networksOf returns the networks m matches by itself, without the ones of
+// included records.
+func networksOf(m Mechanism) []*net.IPNet {
+	switch v := m.(type) {
+	case A:
+		return v.Networks
+	case MX:
+		return v.Networks
+	case IP:
+		return []*net.IPNet{v.Network}
+	}
+	return nil
+}
+
+// walk calls fn for every mechanism of the record and of the records reached
+// through include and redirect, in evaluation order. path holds the include
+// and redirect terms leading to m.
+func (spf *SPF) walk(fn func(path []string, m Mechanism)) {
+	spf.walkPath(nil, fn)
+}
+
+func (spf *SPF) walkPath(path []string, fn func(path []string, m Mechanism)) {
+	for _, v := range spf.Mechanisms {
+		fn(path, v)
+		if i, ok := v.(Include); ok {
+			i.spf.walkPath(append(path[:len(path):len(path)], i.Record), fn)
+		}
+	}
+	if spf.Redirect != nil {
+		fn(path, *spf.Redirect)
+		spf.Redirect.spf.walkPath(append(path[:len(path):len(path)], spf.Redirect.Record), fn)
+	}
+}
+
+func New(domain string, res resolver, opts ...Option) (spf SPF, errRtn error) {
 	spf.Domain = domain
+	spf.opts = opts
+	for _, opt := range opts {
+		opt(&spf)
+	}
+	if _, ok := res.(observedResolver); !ok && spf.o != nil {
+		res = observedResolver{r: res, o: spf.o}
+	}
 	spf.r = res
+	name := canonicalName(domain)
+	for _, d := range spf.path {
+		if d == name {
+			errRtn = fmt.Errorf("%w - %w - %s", PermanentError, LookupLoop, strings.Join(append(spf.path, name), " -> "))
+			return
+		}
+	}
+	spf.path = append(spf.path[:len(spf.path):len(spf.path)], name)
 	txt, err := res.TextRecord(domain)
-	if err != nil {
-		errRtn = fmt.Errorf("%w - %s", DNSResolutionError, err)
+	if isTemporary(err) {
+		if !errors.Is(err, TemporaryError) {
+			err = fmt.Errorf("%w - %w", TemporaryError, err)
+		}
+		errRtn = fmt.Errorf("%w - %w", DNSResolutionError, err)
+		return
 	}
 	for _, v := range txt {
 		if strings.HasPrefix(v, "v=spf1") {
@@ -115,5 +380,25 @@ func New(domain string, res resolver) (spf SPF, errRtn error) {
 		return
 	}
 	errRtn = spf.Parse()
+	// the budget only bounds resolving the tree, later edits count their own
+	spf.budget = nil
 	return
 }
+
+// isTemporary reports whether err is a failure RFC 7208 treats as temperror:
+// a spent query budget, a timeout or SERVFAIL.
+func isTemporary(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout) {
+		return true
+	}
+	return errors.Is(err, TemporaryError)
+}
+
+// isNotFound reports whether err says the name does not exist. RFC 7208
+// treats such an answer to an a or mx lookup as a void lookup, not an
+// error.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
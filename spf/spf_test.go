@@ -2,7 +2,9 @@ package spf
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"net/netip"
 	"reflect"
 	"testing"
 )
@@ -10,11 +12,13 @@ import (
 type txtDomainPair map[string][]string
 type aDomainPair map[string][]net.IP
 type mxDomainPair map[string][]*net.MX
+type nsDomainPair map[string][]*net.NS
 
 type MockResolver struct {
 	txtDomains     txtDomainPair
 	mxDomains      mxDomainPair
 	aDomains       aDomainPair
+	nsDomains      nsDomainPair
 	errorsToReturn map[string]error
 }
 
@@ -47,6 +51,16 @@ func (m MockResolver) MXRecord(domain string) ([]*net.MX, error) {
 	return []*net.MX{}, nil
 }
 
+func (m MockResolver) NSRecord(domain string) ([]*net.NS, error) {
+	if v, ok := m.nsDomains[domain]; ok {
+		return v, m.errorsToReturn[domain]
+	}
+	if v, ok := m.errorsToReturn[domain]; ok {
+		return []*net.NS{}, v
+	}
+	return []*net.NS{}, nil
+}
+
 func TestNewSPF(t *testing.T) {
 	exampleRecord := "v=spf1 a mx -all"
 	testDomain := "test.com"
@@ -181,3 +195,195 @@ func TestSPFMatches(t *testing.T) {
 	}
 
 }
+
+func TestSPFVerdict(t *testing.T) {
+	mainDomain := "test.com"
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			mainDomain:         []string{"v=spf1 ip4:192.168.1.0/24 redirect=other.test.com"},
+			"other.test.com":   []string{"v=spf1 ~ip4:10.0.0.0/8 -all"},
+			"withall.test.com": []string{"v=spf1 ip4:192.168.1.0/24 ?all redirect=other.test.com"},
+			"noall.test.com":   []string{"v=spf1 ip4:192.168.1.0/24"},
+		},
+	}
+	TestTable := []struct {
+		domain    string
+		ip        net.IP
+		qualifier Qualifier
+		match     []string
+	}{
+		{mainDomain, net.ParseIP("192.168.1.5"), Pass, []string{"ip4:192.168.1.0/24"}},
+		{mainDomain, net.ParseIP("10.1.1.1"), Softfail,
+			[]string{"redirect=other.test.com", "~ip4:10.0.0.0/8"}},
+		{mainDomain, net.ParseIP("172.16.1.1"), Fail,
+			[]string{"redirect=other.test.com", "-all"}},
+		{"withall.test.com", net.ParseIP("10.1.1.1"), Neutral, []string{"?all"}},
+		{"noall.test.com", net.ParseIP("10.1.1.1"), Neutral, []string{}},
+	}
+	for _, testCase := range TestTable {
+		spf, err := New(testCase.domain, res)
+		if err != nil {
+			t.Fatalf("creating SPF should not have failed but got %q", err)
+		}
+		q, m, err := spf.Verdict(testCase.ip)
+		if err != nil {
+			t.Errorf("verdict should not have failed but got %q", err)
+		}
+		if q != testCase.qualifier || !reflect.DeepEqual(m, testCase.match) {
+			t.Errorf("wrong verdict for %s on %s wanted %v %v got %v %v",
+				testCase.ip, testCase.domain, testCase.qualifier, testCase.match, q, m)
+		}
+	}
+	spf, _ := New(mainDomain, res)
+	m, _ := spf.Match(net.ParseIP("10.1.1.1"))
+	if !reflect.DeepEqual(m, []string{"redirect=other.test.com", "~ip4:10.0.0.0/8"}) {
+		t.Errorf("match did not follow redirect got %v", m)
+	}
+}
+
+func TestIncludeResults(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":       []string{"v=spf1 include:inner.test.com -all"},
+			"inner.test.com": []string{"v=spf1 -ip4:10.0.0.1 ip4:10.0.0.0/8 ?all"},
+			"open.com":       []string{"v=spf1 include:open.test.com -all"},
+			"open.test.com":  []string{"v=spf1 +all"},
+			"down.com":       []string{"v=spf1 include:down.test.com -all"},
+			"missing.com":    []string{"v=spf1 include:missing.test.com -all"},
+		},
+		errorsToReturn: map[string]error{"down.test.com": TemporaryError},
+	}
+	TestTable := []struct {
+		domain    string
+		ip        net.IP
+		qualifier Qualifier
+		match     []string
+	}{
+		{"test.com", net.ParseIP("10.0.0.2"), Pass, []string{"include:inner.test.com", "ip4:10.0.0.0/8"}},
+		{"test.com", net.ParseIP("10.0.0.1"), Fail, []string{"-all"}},
+		{"test.com", net.ParseIP("192.0.2.1"), Fail, []string{"-all"}},
+		{"open.com", net.ParseIP("192.0.2.1"), Pass, []string{"include:open.test.com", "+all"}},
+	}
+	for _, testCase := range TestTable {
+		spf, err := New(testCase.domain, res)
+		if err != nil {
+			t.Fatalf("creating SPF should not have failed but got %q", err)
+		}
+		q, m, err := spf.Verdict(testCase.ip)
+		if err != nil {
+			t.Errorf("verdict should not have failed but got %q", err)
+		}
+		if q != testCase.qualifier || !reflect.DeepEqual(m, testCase.match) {
+			t.Errorf("wrong verdict for %s on %s wanted %v %v got %v %v",
+				testCase.ip, testCase.domain, testCase.qualifier, testCase.match, q, m)
+		}
+	}
+	spf, _ := New("test.com", res)
+	if m, _ := spf.Match(net.ParseIP("10.0.0.1")); len(m) != 0 {
+		t.Errorf("include should not match on an inner fail got %v", m)
+	}
+	sets, err := spf.Authorized()
+	if err != nil {
+		t.Fatalf("authorized addresses should not have failed but got %q", err)
+	}
+	for _, p := range sets[Pass].Prefixes {
+		if p.Contains(netip.MustParseAddr("10.0.0.1")) {
+			t.Errorf("address failing inside the include is counted as pass by %s", p)
+		}
+	}
+	if _, err := New("down.com", res); !errors.Is(err, TemporaryError) {
+		t.Errorf("temperror of the included record should be kept got %v", err)
+	}
+	if _, err := New("missing.com", res); !errors.Is(err, PermanentError) || errors.Is(err, NoSPFRecordPublished) {
+		t.Errorf("include without a record should be a permerror got %v", err)
+	}
+}
+
+func TestNewSPFTemporaryFailure(t *testing.T) {
+	TestTable := []struct {
+		err       error
+		temporary bool
+	}{
+		{&net.DNSError{Err: "i/o timeout", Name: "test.com", IsTimeout: true}, true},
+		{&net.DNSError{Err: "server misbehaving", Name: "test.com", IsTemporary: true}, true},
+		{TemporaryError, true},
+		{&net.DNSError{Err: "no such host", Name: "test.com", IsNotFound: true}, false},
+	}
+	for _, testCase := range TestTable {
+		res := MockResolver{errorsToReturn: map[string]error{"test.com": testCase.err}}
+		_, err := New("test.com", res)
+		if errors.Is(err, TemporaryError) != testCase.temporary {
+			t.Errorf("wrong temporary state for %v got %v", testCase.err, err)
+		}
+		if !testCase.temporary && !errors.Is(err, NoSPFRecordPublished) {
+			t.Errorf("permanent failure should give no record got %v", err)
+		}
+	}
+}
+
+func TestLookupLoop(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"self.com":       []string{"v=spf1 include:self.com -all"},
+			"a.com":          []string{"v=spf1 include:b.com -all"},
+			"b.com":          []string{"v=spf1 ip4:10.0.0.1 include:A.com. -all"},
+			"redirect.com":   []string{"v=spf1 ip4:10.0.0.1 redirect=redirect.com"},
+			"redirect-a.com": []string{"v=spf1 redirect=redirect-b.com"},
+			"redirect-b.com": []string{"v=spf1 include:redirect-a.com ?all"},
+			"diamond.com":    []string{"v=spf1 include:left.com include:right.com -all"},
+			"left.com":       []string{"v=spf1 include:shared.com -all"},
+			"right.com":      []string{"v=spf1 include:shared.com -all"},
+			"shared.com":     []string{"v=spf1 ip4:10.0.0.1 -all"},
+		},
+	}
+	TestTable := []struct {
+		domain string
+		loop   bool
+	}{
+		{"self.com", true},
+		{"a.com", true},
+		{"redirect.com", true},
+		{"redirect-a.com", true},
+		{"diamond.com", false},
+	}
+	for _, testCase := range TestTable {
+		_, err := New(testCase.domain, res)
+		if testCase.loop && (!errors.Is(err, LookupLoop) || !errors.Is(err, PermanentError)) {
+			t.Errorf("%s should have been refused as a loop got %v", testCase.domain, err)
+		}
+		if !testCase.loop && err != nil {
+			t.Errorf("%s should not have failed but got %q", testCase.domain, err)
+		}
+	}
+}
+
+func TestLookupLimits(t *testing.T) {
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"void.com": []string{"v=spf1 a:x.void.com a:y.void.com mx:z.void.com -all"},
+			"ok.com":   []string{"v=spf1 a:x.void.com mx:z.void.com ip4:10.0.0.1 -all"},
+		},
+	}
+	for i := 0; i < 11; i++ {
+		res.txtDomains[fmt.Sprintf("%d.chain.com", i)] = []string{fmt.Sprintf("v=spf1 include:%d.chain.com -all", i+1)}
+	}
+	res.txtDomains["11.chain.com"] = []string{"v=spf1 ip4:10.0.0.1 -all"}
+	TestTable := []struct {
+		domain string
+		err    error
+	}{
+		{"0.chain.com", TooManyLookups},
+		{"1.chain.com", nil},
+		{"void.com", TooManyVoidLookups},
+		{"ok.com", nil},
+	}
+	for _, testCase := range TestTable {
+		_, err := New(testCase.domain, res)
+		if testCase.err == nil && err != nil {
+			t.Errorf("%s should not have failed but got %q", testCase.domain, err)
+		}
+		if testCase.err != nil && (!errors.Is(err, testCase.err) || !errors.Is(err, PermanentError)) {
+			t.Errorf("%s should have failed with %q got %v", testCase.domain, testCase.err, err)
+		}
+	}
+}
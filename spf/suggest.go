@@ -0,0 +1,109 @@
+package spf
+
+import (
+	"net"
+	"sort"
+)
+
+// IncludeSuggestion is an include of a known provider that would authorize
+// a failing address. Chain leads from the include to the matching term of
+// the provider's record. Record is the record with the include inserted and
+// Lookups what the include adds to the Total the record then needs. Err is
+// set instead of Record when the edit is not possible, typically because it
+// would exceed MaxLookups.
+type IncludeSuggestion struct {
+	Provider Provider
+	Term     string
+	Chain    []string
+	Record   string
+	Lookups  int
+	Total    int
+	Err      error
+}
+
+// SuggestIncludes builds the record of domain and suggests includes for ip,
+// see SPF.SuggestIncludes.
+func SuggestIncludes(domain string, ip net.IP, res resolver, opts ...Option) ([]IncludeSuggestion, error) {
+	spf, err := New(domain, res, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return spf.SuggestIncludes(ip), nil
+}
+
+// SuggestIncludes suggests includes for ip, see IncludeSuggester.Suggest.
+// Use an IncludeSuggester to check several addresses without resolving the
+// catalog again for each.
+func (spf *SPF) SuggestIncludes(ip net.IP) []IncludeSuggestion {
+	return spf.IncludeSuggester().Suggest(ip)
+}
+
+// IncludeSuggester holds the resolved include domains of a catalog for one
+// record.
+type IncludeSuggester struct {
+	spf        *SPF
+	candidates []suggestCandidate
+}
+
+type suggestCandidate struct {
+	provider Provider
+	include  Include
+	lookups  int
+}
+
+// IncludeSuggester resolves the records of every include domain of the
+// catalog once. Include domains already in the record and ones without a
+// usable record are skipped.
+func (spf *SPF) IncludeSuggester() *IncludeSuggester {
+	s := &IncludeSuggester{spf: spf}
+	e := spf.Edit()
+	for _, p := range spf.providers().Providers() {
+		for _, d := range p.Includes {
+			term := "include:" + canonicalName(d)
+			if e.find(term) >= 0 {
+				continue
+			}
+			i, err := NewInclude(term, spf.r, spf.childOpts(nil)...)
+			if err != nil {
+				continue
+			}
+			s.candidates = append(s.candidates, suggestCandidate{
+				provider: p,
+				include:  i,
+				lookups:  termLookups(recordTerm{term: term, m: i}),
+			})
+		}
+	}
+	return s
+}
+
+// Suggest returns the includes that match ip, cheapest first. An include
+// matches when the provider's record gives pass, as it would once included.
+// Nothing is suggested when ip already gets pass.
+func (s *IncludeSuggester) Suggest(ip net.IP) (suggestions []IncludeSuggestion) {
+	if q, _, err := s.spf.Verdict(ip); err == nil && q == Pass {
+		return nil
+	}
+	for _, c := range s.candidates {
+		chain, err := c.include.Match(ip)
+		if err != nil || len(chain) == 0 {
+			continue
+		}
+		e := s.spf.Edit()
+		suggestion := IncludeSuggestion{
+			Provider: c.provider,
+			Term:     c.include.Record,
+			Chain:    chain,
+			Lookups:  c.lookups,
+			Total:    e.Lookups() + c.lookups,
+		}
+		if suggestion.Err = e.insert(c.include.Record, c.include); suggestion.Err == nil {
+			suggestion.Record = e.String()
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Lookups < suggestions[j].Lookups
+	})
+	return
+}
@@ -0,0 +1,86 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestSuggestIncludes(t *testing.T) {
+	catalog := NewCatalog(
+		Provider{Name: "Big Mail", Includes: []string{"_spf.big.example"}},
+		Provider{Name: "Small Mail", Includes: []string{"spf.small.example", "missing.small.example"}},
+		Provider{Name: "Other Mail", Includes: []string{"spf.other.example"}},
+		Provider{Name: "Soft Mail", Includes: []string{"spf.soft.example"}},
+		Provider{Name: "Deny Mail", Includes: []string{"spf.deny.example"}},
+	)
+	res := MockResolver{
+		txtDomains: txtDomainPair{
+			"test.com":          []string{"v=spf1 mx include:spf.other.example ~all"},
+			"full.com":          []string{"v=spf1 a a a a a a a a ~all"},
+			"_spf.big.example":  []string{"v=spf1 include:_a.big.example include:_b.big.example -all"},
+			"_a.big.example":    []string{"v=spf1 ip4:198.51.100.0/24 -all"},
+			"_b.big.example":    []string{"v=spf1 ip4:192.0.2.0/24 -all"},
+			"spf.small.example": []string{"v=spf1 ip4:192.0.2.0/25 -all"},
+			"spf.other.example": []string{"v=spf1 ip4:203.0.113.0/24 -all"},
+			"spf.soft.example":  []string{"v=spf1 ~ip4:192.0.2.0/24 -all"},
+			"spf.deny.example":  []string{"v=spf1 -ip4:192.0.2.10 ip4:192.0.2.0/24 -all"},
+		},
+		aDomains: aDomainPair{"full.com": {net.ParseIP("198.51.100.200")}},
+	}
+	ip := net.ParseIP("192.0.2.10")
+	got, err := SuggestIncludes("test.com", ip, res, WithCatalog(catalog))
+	if err != nil {
+		t.Fatalf("suggesting should not have failed but got %q", err)
+	}
+	TestTable := []struct {
+		provider string
+		record   string
+		lookups  int
+		total    int
+		chain    int
+	}{
+		{"Small Mail", "v=spf1 mx include:spf.other.example include:spf.small.example ~all", 1, 3, 2},
+		{"Big Mail", "v=spf1 mx include:spf.other.example include:_spf.big.example ~all", 3, 5, 3},
+	}
+	if len(got) != len(TestTable) {
+		t.Fatalf("wanted %d suggestions got %+v", len(TestTable), got)
+	}
+	for i, test := range TestTable {
+		s := got[i]
+		if s.Provider.Name != test.provider || s.Record != test.record || s.Lookups != test.lookups ||
+			s.Total != test.total || len(s.Chain) != test.chain || s.Err != nil {
+			t.Errorf("wrong suggestion %d wanted %+v got %+v", i, test, s)
+		}
+	}
+
+	if got, _ := SuggestIncludes("test.com", net.ParseIP("203.0.113.5"), res, WithCatalog(catalog)); len(got) != 0 {
+		t.Errorf("passing address should not get suggestions got %+v", got)
+	}
+
+	got, err = SuggestIncludes("full.com", ip, res, WithCatalog(catalog))
+	if err != nil {
+		t.Fatalf("suggesting should not have failed but got %q", err)
+	}
+	if len(got) != 2 || got[1].Provider.Name != "Big Mail" {
+		t.Fatalf("wanted the big include last got %+v", got)
+	}
+	if s := got[1]; !errors.Is(s.Err, TooManyLookups) || s.Record != "" || s.Total != 11 {
+		t.Errorf("include over the limit should fail got %+v", s)
+	}
+
+	counting := &countingResolver{resolver: res, queries: make(map[string]int)}
+	spf, err := New("test.com", counting, WithCatalog(catalog))
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	s := spf.IncludeSuggester()
+	for _, ip := range []string{"192.0.2.10", "192.0.2.20", "198.51.100.1"} {
+		if got := s.Suggest(net.ParseIP(ip)); len(got) == 0 {
+			t.Errorf("%s should have got suggestions", ip)
+		}
+	}
+	if n := counting.queries["TXT _spf.big.example"]; n != 1 {
+		t.Errorf("provider record should be resolved once got %d queries", n)
+	}
+}
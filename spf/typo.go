@@ -0,0 +1,344 @@
+package spf
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Typo is a term that Parse would drop or misread, together with the term
+// that was most likely intended. An empty Suggestion means the term should
+// be removed.
+type Typo struct {
+	Term       string
+	Problem    string
+	Suggestion string
+}
+
+var knownTerms = []string{"all", "include", "a", "mx", "ptr", "ip4", "ip6", "exists", "redirect", "exp"}
+
+// lookalikes maps characters that commonly sneak in when records are copied
+// from documents or chat to what was meant. Zero means drop the character.
+var lookalikes = map[rune]rune{
+	'‘': 0, '’': 0, '‚': 0, '“': 0, '”': 0, '„': 0, '"': 0, '\'': 0,
+	'‐': '-', '‑': '-', '‒': '-', '–': '-', '—': '-', '−': '-',
+	'\u200b': 0, '\u200c': 0, '\u200d': 0, '\ufeff': 0,
+	'：': ':', '＝': '=', '／': '/', '～': '~',
+}
+
+// Typos returns the suspicious terms of the record.
+func (spf *SPF) Typos() []Typo {
+	return FindTypos(spf.Record)
+}
+
+// FindTypos looks for near-miss mechanism names, stray Unicode, wrong
+// separators and malformed addresses or CIDR lengths in record.
+func FindTypos(record string) []Typo {
+	typos, _ := checkTypos(record)
+	return typos
+}
+
+// CorrectRecord returns record with every suggestion of FindTypos applied.
+func CorrectRecord(record string) string {
+	_, corrected := checkTypos(record)
+	return strings.Join(corrected, " ")
+}
+
+func checkTypos(record string) (typos []Typo, corrected []string) {
+	if strings.IndexFunc(record, func(r rune) bool { return unicode.IsSpace(r) && r != ' ' }) >= 0 {
+		typos = append(typos, Typo{Problem: "record contains whitespace other than plain spaces"})
+	}
+	tokens := strings.FieldsFunc(record, unicode.IsSpace)
+	for i := 0; i < len(tokens); i++ {
+		original := tokens[i]
+		term, problems := cleanTerm(original)
+		if term == "" {
+			typos = append(typos, Typo{Term: original, Problem: strings.Join(problems, ", ")})
+			continue
+		}
+		if len(corrected) == 0 {
+			if !strings.EqualFold(term, "v=spf1") {
+				if termDistance(strings.ToLower(term), "v=spf1") <= 2 {
+					problems = append(problems, "malformed version")
+					term = "v=spf1"
+				} else {
+					corrected = append(corrected, "v=spf1")
+					typos = append(typos, Typo{Problem: "record does not start with v=spf1", Suggestion: "v=spf1"})
+				}
+			}
+		} else if strings.EqualFold(term, "v=spf1") {
+			typos = append(typos, Typo{Term: original, Problem: "version repeated inside the record"})
+			continue
+		}
+		if !strings.EqualFold(term, "v=spf1") {
+			var more []string
+			var merged bool
+			next := ""
+			if i+1 < len(tokens) {
+				next, _ = cleanTerm(tokens[i+1])
+			}
+			term, more, merged = fixTerm(term, next)
+			problems = append(problems, more...)
+			if merged {
+				original += " " + tokens[i+1]
+				i++
+			}
+		}
+		if len(problems) > 0 {
+			typos = append(typos, Typo{Term: original, Problem: strings.Join(problems, ", "), Suggestion: term})
+		}
+		if term != "" {
+			corrected = append(corrected, term)
+		}
+	}
+	return
+}
+
+// cleanTerm replaces lookalike characters and strips trailing punctuation.
+func cleanTerm(term string) (string, []string) {
+	var problems []string
+	var b strings.Builder
+	for _, r := range term {
+		if v, ok := lookalikes[r]; ok {
+			if r > unicode.MaxASCII {
+				problems = append(problems, fmt.Sprintf("stray character %U", r))
+			} else {
+				problems = append(problems, fmt.Sprintf("stray %q", r))
+			}
+			if v != 0 {
+				b.WriteRune(v)
+			}
+			continue
+		}
+		if r > unicode.MaxASCII {
+			problems = append(problems, fmt.Sprintf("stray character %U", r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	cleaned := b.String()
+	cutset := ",;"
+	if !strings.ContainsAny(cleaned, ":=") {
+		cutset += "."
+	}
+	if trimmed := strings.TrimRight(cleaned, cutset); trimmed != cleaned {
+		problems = append(problems, "trailing punctuation")
+		cleaned = trimmed
+	}
+	return cleaned, problems
+}
+
+// fixTerm checks the name, separator and argument of a single term. next is
+// the following term; merged reports whether it was taken as the argument of
+// a mechanism that lost its colon.
+func fixTerm(term string, next string) (fixed string, problems []string, merged bool) {
+	qualifier := ""
+	if strings.ContainsAny(term[:1], "+-~?") && len(term) > 1 {
+		qualifier, term = term[:1], term[1:]
+	}
+	i := strings.IndexAny(term, ":=/;")
+	name, sep, arg := strings.ToLower(term), "", ""
+	if i >= 0 {
+		name, sep, arg = strings.ToLower(term[:i]), term[i:i+1], term[i+1:]
+	}
+	if name == "" {
+		return "", []string{"term without a mechanism name"}, false
+	}
+	if sep == "=" && !isKnownTerm(name) {
+		// evaluation ignores unknown modifiers (RFC 7208 section 6), only
+		// near-misses of redirect and exp are taken for typos
+		guess := closestModifier(name)
+		if guess == "" {
+			return qualifier + term, nil, false
+		}
+		problems = append(problems, fmt.Sprintf("unknown modifier %q", name))
+		name = guess
+	}
+	if !isKnownTerm(name) {
+		if guess := closestTerm(name); guess != "" {
+			problems = append(problems, fmt.Sprintf("unknown mechanism %q", name))
+			name = guess
+		} else {
+			return "", []string{fmt.Sprintf("unknown mechanism %q", name)}, false
+		}
+	}
+	isModifier := name == "redirect" || name == "exp"
+	needsArg := isModifier || name == "include" || name == "ip4" || name == "ip6" || name == "exists"
+	if sep == "" && needsArg && looksLikeArgument(name, next) {
+		problems = append(problems, "missing colon")
+		sep, arg, merged = ":", next, true
+	}
+	switch {
+	case isModifier && sep != "" && sep != "=":
+		problems = append(problems, fmt.Sprintf("%s needs = instead of %s", name, sep))
+		sep = "="
+	case !isModifier && (sep == "=" || sep == ";"):
+		problems = append(problems, fmt.Sprintf("%s needs : instead of %s", name, sep))
+		sep = ":"
+	}
+	if isModifier {
+		qualifier = ""
+	}
+	if name == "ip4" || name == "ip6" {
+		name, arg, problems = fixAddress(name, arg, problems)
+		if arg == "" {
+			return "", problems, merged
+		}
+	}
+	if name == "a" || name == "mx" {
+		arg, problems = fixDualCIDR(sep+arg, problems)
+		sep = ""
+	}
+	return qualifier + name + sep + arg, problems, merged
+}
+
+func isKnownTerm(name string) bool {
+	for _, v := range knownTerms {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+// closestTerm returns the known term name within a small edit distance of
+// name, or "" when nothing is close enough.
+func closestTerm(name string) string {
+	best, bestDistance := "", 3
+	if len(name) < 2 {
+		return best
+	}
+	for _, v := range knownTerms {
+		limit := 2
+		if len(v) <= 3 {
+			limit = 1
+		}
+		if d := termDistance(name, v); d <= limit && d < bestDistance {
+			best, bestDistance = v, d
+		}
+	}
+	return best
+}
+
+// closestModifier returns redirect or exp when name is at most two edits
+// away from it, or "" otherwise.
+func closestModifier(name string) string {
+	best, bestDistance := "", 3
+	for _, v := range []string{"redirect", "exp"} {
+		if d := termDistance(name, v); d < bestDistance {
+			best, bestDistance = v, d
+		}
+	}
+	return best
+}
+
+// termDistance is the optimal string alignment distance, a Levenshtein
+// distance that also counts swapped neighbours as a single edit.
+func termDistance(a string, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func looksLikeArgument(name string, next string) bool {
+	if next == "" {
+		return false
+	}
+	if name == "ip4" || name == "ip6" {
+		addr, _, _ := strings.Cut(next, "/")
+		return net.ParseIP(addr) != nil
+	}
+	return !isKnownTerm(termName(next)) && strings.Contains(next, ".")
+}
+
+// fixAddress validates the address and prefix length of an ip4 or ip6 term,
+// switching the version when the address belongs to the other one.
+func fixAddress(name string, arg string, problems []string) (string, string, []string) {
+	addr, cidr, hasCIDR := strings.Cut(arg, "/")
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return name, "", append(problems, fmt.Sprintf("%q is not an IP address", addr))
+	}
+	if ip.To4() != nil && name == "ip6" && !strings.Contains(addr, ":") {
+		problems = append(problems, "IPv4 address in ip6")
+		name = "ip4"
+	}
+	if ip.To4() == nil && name == "ip4" {
+		problems = append(problems, "IPv6 address in ip4")
+		name = "ip6"
+	}
+	if !hasCIDR {
+		return name, addr, problems
+	}
+	max := 32
+	if name == "ip6" {
+		max = 128
+	}
+	n, err := strconv.Atoi(cidr)
+	switch {
+	case err != nil || n < 0:
+		return name, addr, append(problems, fmt.Sprintf("malformed prefix length %q", cidr))
+	case n > max:
+		return name, fmt.Sprintf("%s/%d", addr, max), append(problems, fmt.Sprintf("prefix length /%d longer than /%d", n, max))
+	}
+	return name, addr + "/" + strconv.Itoa(n), problems
+}
+
+// fixDualCIDR clamps the prefix lengths of the [:domain][/cidr4][//cidr6]
+// part of an a or mx term.
+func fixDualCIDR(rest string, problems []string) (string, []string) {
+	domain, cidrs, _ := strings.Cut(rest, "/")
+	if cidrs == "" {
+		return rest, problems
+	}
+	cidr4, cidr6, dual := strings.Cut(cidrs, "/")
+	if dual && strings.HasPrefix(cidr6, "/") {
+		cidr6 = cidr6[1:]
+	}
+	clamp := func(v string, max int) string {
+		n, err := strconv.Atoi(v)
+		if v == "" || err != nil {
+			return v
+		}
+		if n > max {
+			problems = append(problems, fmt.Sprintf("prefix length /%d longer than /%d", n, max))
+			n = max
+		}
+		return strconv.Itoa(n)
+	}
+	fixed := domain
+	if cidr4 != "" {
+		fixed += "/" + clamp(cidr4, 32)
+	}
+	if dual {
+		fixed += "//" + clamp(cidr6, 128)
+	}
+	return fixed, problems
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,103 @@
+package spf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindTypos(t *testing.T) {
+	TestTable := []struct {
+		testCase string
+		record   string
+		want     []Typo
+	}{
+		{
+			testCase: "clean record",
+			record:   "v=spf1 a mx include:_spf.test.com ip4:10.0.0.0/8 ~all",
+			want:     nil,
+		},
+		{
+			testCase: "misspelled include",
+			record:   "v=spf1 inlcude:_spf.test.com -all",
+			want:     []Typo{{"inlcude:_spf.test.com", `unknown mechanism "inlcude"`, "include:_spf.test.com"}},
+		},
+		{
+			testCase: "space instead of colon",
+			record:   "v=spf1 ip4 1.2.3.4 -all",
+			want:     []Typo{{"ip4 1.2.3.4", "missing colon", "ip4:1.2.3.4"}},
+		},
+		{
+			testCase: "prefix too long",
+			record:   "v=spf1 ip4:1.2.3.4/33 a/40 -all",
+			want: []Typo{
+				{"ip4:1.2.3.4/33", "prefix length /33 longer than /32", "ip4:1.2.3.4/32"},
+				{"a/40", "prefix length /40 longer than /32", "a/32"},
+			},
+		},
+		{
+			testCase: "wrong address family",
+			record:   "v=spf1 ip4:2001:db8::/32 -all",
+			want:     []Typo{{"ip4:2001:db8::/32", "IPv6 address in ip4", "ip6:2001:db8::/32"}},
+		},
+		{
+			testCase: "smart quotes and en dash",
+			record:   "“v=spf1 mx –all”",
+			want: []Typo{
+				{"“v=spf1", "stray character U+201C", "v=spf1"},
+				{"–all”", "stray character U+2013, stray character U+201D", "-all"},
+			},
+		},
+		{
+			testCase: "duplicated version",
+			record:   "v=spf1 v=spf1 mx -all",
+			want:     []Typo{{"v=spf1", "version repeated inside the record", ""}},
+		},
+		{
+			testCase: "modifier with colon",
+			record:   "v=spf1 redirect:_spf.test.com",
+			want:     []Typo{{"redirect:_spf.test.com", "redirect needs = instead of :", "redirect=_spf.test.com"}},
+		},
+		{
+			testCase: "non breaking space",
+			record:   "v=spf1 mx -all.",
+			want: []Typo{
+				{"", "record contains whitespace other than plain spaces", ""},
+				{"-all.", "trailing punctuation", "-all"},
+			},
+		},
+		{
+			testCase: "unknown modifier",
+			record:   "v=spf1 mx foo=bar -all",
+			want:     nil,
+		},
+		{
+			testCase: "misspelled redirect",
+			record:   "v=spf1 mx redirct=_spf.test.com",
+			want:     []Typo{{"redirct=_spf.test.com", `unknown modifier "redirct"`, "redirect=_spf.test.com"}},
+		},
+		{
+			testCase: "misspelled exp",
+			record:   "v=spf1 mx ex=explain.test.com -all",
+			want:     []Typo{{"ex=explain.test.com", `unknown modifier "ex"`, "exp=explain.test.com"}},
+		},
+		{
+			testCase: "unrecognisable term",
+			record:   "v=spf1 foobar:x -all",
+			want:     []Typo{{"foobar:x", `unknown mechanism "foobar"`, ""}},
+		},
+	}
+
+	for _, test := range TestTable {
+		if got := FindTypos(test.record); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: wrong typos wanted\n%q\ngot\n%q", test.testCase, test.want, got)
+		}
+	}
+}
+
+func TestCorrectRecord(t *testing.T) {
+	got := CorrectRecord("“v=spf1 inlcude:_spf.test.com ip4 1.2.3.4 v=spf1 ip4:10.0.0.0/33 –all”")
+	want := "v=spf1 include:_spf.test.com ip4:1.2.3.4 ip4:10.0.0.0/32 -all"
+	if got != want {
+		t.Errorf("wrong corrected record wanted %q got %q", want, got)
+	}
+}
@@ -3,17 +3,19 @@ package spf
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"log"
 )
 
 const (
-	aMXRegex     = `^([+-~]){0,1}(a|mx)(?::([a-zA-Z0-9-._]+)){0,1}(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
-	aRegex       = `^([+-~]){0,1}(a)(?::([a-zA-Z0-9-._]+)){0,1}(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
-	mxRegex      = `^([+-~]){0,1}(mx)(?::([a-zA-Z0-9-._]+)){0,1}(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
-	includeRegex = `^([+-~]){0,1}include(?::([a-zA-Z0-9-._]+)){0,1}$`
-	ipRegex      = `^([+-~]){0,1}ip([46])(?::([0-9.:a-f]*))(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
-	allRegex     = `^([+-~])all$`
+	aMXRegex      = `^([+-~]){0,1}(a|mx)(?::([a-zA-Z0-9-._]+)){0,1}(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
+	aRegex        = `^([+-~]){0,1}(a)(?::([a-zA-Z0-9-._]+)){0,1}(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
+	mxRegex       = `^([+-~]){0,1}(mx)(?::([a-zA-Z0-9-._]+)){0,1}(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
+	includeRegex  = `^([+-~]){0,1}include(?::([a-zA-Z0-9-._]+)){0,1}$`
+	ipRegex       = `^([+-~]){0,1}ip([46])(?::([0-9.:a-f]*))(?:\/(3[0-2]|[12][0-9]|[1-9])){0,1}(?:\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])){0,1}$`
+	allRegex      = `^([+-~])all$`
+	redirectRegex = `^redirect=([a-zA-Z0-9-._]+)$`
 )
 
 func IsAMechanism(mechanism string) bool {
@@ -61,6 +63,15 @@ func isIPMechanism(mechanism string) bool {
 	return match
 }
 
+func isRedirectModifier(modifier string) bool {
+	match, err := regexp.MatchString(redirectRegex, modifier)
+	if err != nil {
+		log.Printf("regex error %s", err)
+		return false
+	}
+	return match
+}
+
 func matchQualifier(q string) (qualifier Qualifier) {
 	switch q {
 	case "~":
@@ -140,9 +151,37 @@ func matchIP(part string) (qualifier string, version string, ip string, cidr str
 	ip = components[3]
 	if version == "6" {
 		cidr = components[5]
+		if cidr == "" {
+			// prefixes up to /32 are picked up by the IPv4 group
+			cidr = components[4]
+		}
 	}
 	if version == "4" {
 		cidr = components[4]
 	}
 	return
 }
+
+func matchRedirect(part string) (domain string, errRtn error) {
+	re, err := regexp.Compile(redirectRegex)
+	if err != nil {
+		log.Printf("failed to compile regex %s", err)
+	}
+	components := re.FindStringSubmatch(part)
+	if len(components) != 2 {
+		errRtn = fmt.Errorf("%w - got %s", WrongFormat, part)
+		return
+	}
+	domain = components[1]
+	return
+}
+
+// termName returns the lower case mechanism or modifier name of a record
+// term without its qualifier, e.g. "include" for "~include:example.com".
+func termName(term string) string {
+	term = strings.ToLower(strings.TrimLeft(term, "+-~?"))
+	if i := strings.IndexAny(term, ":/="); i >= 0 {
+		return term[:i]
+	}
+	return term
+}
@@ -0,0 +1,545 @@
+package spf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ZoneFormatError = errors.New("malformed zone file")
+
+const maxCNAMEChain = 8
+
+type zoneRR struct {
+	ttl   uint32
+	rtype string
+	rdata []string
+}
+
+// ZoneResolver answers queries from RFC 1035 master files without touching
+// the network. It understands $ORIGIN, $TTL, parentheses, quoted strings with
+// escapes, CNAME chains and wildcard owners.
+type ZoneResolver struct {
+	records map[string][]zoneRR
+	exists  map[string]bool
+}
+
+func NewZoneResolver() *ZoneResolver {
+	return &ZoneResolver{
+		records: make(map[string][]zoneRR),
+		exists:  make(map[string]bool),
+	}
+}
+
+// LoadFile reads the master file at path. origin is used for relative names
+// until the file sets its own $ORIGIN.
+func (z *ZoneResolver) LoadFile(path string, origin string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := z.Load(f, origin); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Load reads a master file from r. origin is used for relative names until
+// the file sets its own $ORIGIN.
+func (z *ZoneResolver) Load(r io.Reader, origin string) error {
+	p := zoneParser{origin: canonicalName(origin), ttl: 3600}
+	lines, err := zoneLines(r)
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if err := p.parseLine(z, l); err != nil {
+			return fmt.Errorf("%w - line %d: %s", ZoneFormatError, l.number, err)
+		}
+	}
+	return nil
+}
+
+func (z *ZoneResolver) add(name string, rr zoneRR) {
+	z.records[name] = append(z.records[name], rr)
+	for n := name; n != ""; n = parentName(n) {
+		z.exists[n] = true
+	}
+}
+
+// lookup returns the records of type rtype for domain following CNAMEs and
+// expanding wildcards. found is false when the name does not exist at all.
+func (z *ZoneResolver) lookup(domain string, rtype string) (rrs []zoneRR, found bool) {
+	name := canonicalName(domain)
+	for i := 0; i <= maxCNAMEChain; i++ {
+		owner, ok := z.owner(name)
+		if !ok {
+			return nil, false
+		}
+		var cname string
+		for _, rr := range z.records[owner] {
+			if rr.rtype == rtype {
+				rrs = append(rrs, rr)
+			}
+			if rr.rtype == "CNAME" && len(rr.rdata) == 1 {
+				cname = rr.rdata[0]
+			}
+		}
+		if len(rrs) > 0 || cname == "" || rtype == "CNAME" {
+			return rrs, true
+		}
+		name = cname
+	}
+	return nil, true
+}
+
+// owner maps name to the owner name holding its records, which is the name
+// itself or the wildcard at its closest encloser (RFC 4592).
+func (z *ZoneResolver) owner(name string) (string, bool) {
+	if z.exists[name] {
+		return name, true
+	}
+	for encloser := parentName(name); encloser != ""; encloser = parentName(encloser) {
+		if !z.exists[encloser] {
+			continue
+		}
+		wildcard := "*." + encloser
+		if _, ok := z.records[wildcard]; ok {
+			return wildcard, true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// TTL reports the TTL of the records answering domain and rtype.
+func (z *ZoneResolver) TTL(domain string, rtype string) (uint32, bool) {
+	rrs, _ := z.lookup(domain, rtype)
+	if len(rrs) == 0 {
+		return 0, false
+	}
+	ttl := rrs[0].ttl
+	for _, rr := range rrs[1:] {
+		if rr.ttl < ttl {
+			ttl = rr.ttl
+		}
+	}
+	return ttl, true
+}
+
+func notFound(domain string) error {
+	return &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+}
+
+func (z *ZoneResolver) TextRecord(domain string) ([]string, error) {
+	rrs, found := z.lookup(domain, "TXT")
+	if !found {
+		return nil, notFound(domain)
+	}
+	txt := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		txt = append(txt, strings.Join(rr.rdata, ""))
+	}
+	return txt, nil
+}
+
+// TextStrings returns every TXT record of domain split into its strings.
+func (z *ZoneResolver) TextStrings(domain string) ([][]string, error) {
+	rrs, found := z.lookup(domain, "TXT")
+	if !found {
+		return nil, notFound(domain)
+	}
+	var txt [][]string
+	for _, rr := range rrs {
+		txt = append(txt, rr.rdata)
+	}
+	return txt, nil
+}
+
+// SPFTypeRecord returns the records of the deprecated SPF type of domain.
+func (z *ZoneResolver) SPFTypeRecord(domain string) ([]string, error) {
+	rrs, found := z.lookup(domain, "SPF")
+	if !found {
+		return nil, notFound(domain)
+	}
+	var spf []string
+	for _, rr := range rrs {
+		spf = append(spf, strings.Join(rr.rdata, ""))
+	}
+	return spf, nil
+}
+
+func (z *ZoneResolver) ARecord(domain string) ([]net.IP, error) {
+	a, found := z.lookup(domain, "A")
+	if !found {
+		return nil, notFound(domain)
+	}
+	aaaa, _ := z.lookup(domain, "AAAA")
+	var ips []net.IP
+	for _, rr := range append(a, aaaa...) {
+		if len(rr.rdata) != 1 {
+			continue
+		}
+		if ip := net.ParseIP(rr.rdata[0]); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+func (z *ZoneResolver) MXRecord(domain string) ([]*net.MX, error) {
+	rrs, found := z.lookup(domain, "MX")
+	if !found {
+		return nil, notFound(domain)
+	}
+	var mxs []*net.MX
+	for _, rr := range rrs {
+		if len(rr.rdata) != 2 {
+			continue
+		}
+		pref, err := strconv.ParseUint(rr.rdata[0], 10, 16)
+		if err != nil {
+			continue
+		}
+		mxs = append(mxs, &net.MX{Host: rr.rdata[1] + ".", Pref: uint16(pref)})
+	}
+	sort.SliceStable(mxs, func(i, j int) bool { return mxs[i].Pref < mxs[j].Pref })
+	return mxs, nil
+}
+
+func (z *ZoneResolver) NSRecord(domain string) ([]*net.NS, error) {
+	rrs, found := z.lookup(domain, "NS")
+	if !found {
+		return nil, notFound(domain)
+	}
+	var ns []*net.NS
+	for _, rr := range rrs {
+		if len(rr.rdata) == 1 {
+			ns = append(ns, &net.NS{Host: rr.rdata[0] + "."})
+		}
+	}
+	return ns, nil
+}
+
+// PTRRecord returns the names pointed to by the reverse entry of addr.
+func (z *ZoneResolver) PTRRecord(addr string) ([]string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("%w - not an IP address %q", WrongFormat, addr)
+	}
+	name := reverseName(ip)
+	rrs, found := z.lookup(name, "PTR")
+	if !found {
+		return nil, notFound(name)
+	}
+	var names []string
+	for _, rr := range rrs {
+		if len(rr.rdata) == 1 {
+			names = append(names, rr.rdata[0]+".")
+		}
+	}
+	return names, nil
+}
+
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0])
+	}
+	const hex = "0123456789abcdef"
+	var b strings.Builder
+	ip = ip.To16()
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(hex[ip[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hex[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String()
+}
+
+// canonicalName lowercases a domain and strips the trailing dot so that names
+// from zone files and from queries compare equal.
+func canonicalName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func parentName(name string) string {
+	i := strings.IndexByte(name, '.')
+	if i < 0 {
+		return ""
+	}
+	return name[i+1:]
+}
+
+type zoneToken struct {
+	text   string
+	quoted bool
+}
+
+type zoneLine struct {
+	number   int
+	indented bool
+	tokens   []zoneToken
+}
+
+// zoneLines splits a master file into logical lines, joining parenthesised
+// continuations and dropping comments.
+func zoneLines(r io.Reader) ([]zoneLine, error) {
+	var lines []zoneLine
+	var current zoneLine
+	depth := 0
+	s := bufio.NewScanner(r)
+	number := 0
+	for s.Scan() {
+		number++
+		text := s.Text()
+		if depth == 0 {
+			current = zoneLine{
+				number:   number,
+				indented: len(text) > 0 && (text[0] == ' ' || text[0] == '\t'),
+			}
+		}
+		tokens, d, err := tokenizeZoneLine(text, depth)
+		if err != nil {
+			return nil, fmt.Errorf("%w - line %d: %s", ZoneFormatError, number, err)
+		}
+		depth = d
+		current.tokens = append(current.tokens, tokens...)
+		if depth == 0 && len(current.tokens) > 0 {
+			lines = append(lines, current)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w - unbalanced parentheses at line %d", ZoneFormatError, current.number)
+	}
+	return lines, nil
+}
+
+func tokenizeZoneLine(text string, depth int) (tokens []zoneToken, newDepth int, errRtn error) {
+	newDepth = depth
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == ';':
+			return
+		case c == '(':
+			newDepth++
+			i++
+		case c == ')':
+			newDepth--
+			if newDepth < 0 {
+				errRtn = errors.New("unbalanced parentheses")
+				return
+			}
+			i++
+		case c == '"':
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(text) {
+				if text[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				n, err := unescapeZone(text[i:], &b)
+				if err != nil {
+					errRtn = err
+					return
+				}
+				i += n
+			}
+			if !closed {
+				errRtn = errors.New("unterminated quoted string")
+				return
+			}
+			tokens = append(tokens, zoneToken{text: b.String(), quoted: true})
+		default:
+			var b strings.Builder
+			for i < len(text) && !strings.ContainsRune(" \t\r;()\"", rune(text[i])) {
+				n, err := unescapeZone(text[i:], &b)
+				if err != nil {
+					errRtn = err
+					return
+				}
+				i += n
+			}
+			tokens = append(tokens, zoneToken{text: b.String()})
+		}
+	}
+	return
+}
+
+// unescapeZone writes the first character of s to b, decoding \X and \DDD
+// escapes, and returns how many bytes of s were consumed.
+func unescapeZone(s string, b *strings.Builder) (int, error) {
+	if s[0] != '\\' {
+		b.WriteByte(s[0])
+		return 1, nil
+	}
+	if len(s) < 2 {
+		return 0, errors.New("dangling escape")
+	}
+	if s[1] >= '0' && s[1] <= '9' {
+		if len(s) < 4 {
+			return 0, fmt.Errorf("short decimal escape %q", s)
+		}
+		v, err := strconv.ParseUint(s[1:4], 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("bad decimal escape %q", s[:4])
+		}
+		b.WriteByte(byte(v))
+		return 4, nil
+	}
+	b.WriteByte(s[1])
+	return 2, nil
+}
+
+type zoneParser struct {
+	origin    string
+	ttl       uint32
+	lastOwner string
+}
+
+func (p *zoneParser) parseLine(z *ZoneResolver, l zoneLine) error {
+	tokens := l.tokens
+	switch strings.ToUpper(tokens[0].text) {
+	case "$ORIGIN":
+		if len(tokens) != 2 {
+			return errors.New("$ORIGIN needs exactly one name")
+		}
+		p.origin = p.absolute(tokens[1].text)
+		return nil
+	case "$TTL":
+		if len(tokens) != 2 {
+			return errors.New("$TTL needs exactly one value")
+		}
+		ttl, err := parseTTL(tokens[1].text)
+		if err != nil {
+			return err
+		}
+		p.ttl = ttl
+		return nil
+	}
+	if strings.HasPrefix(tokens[0].text, "$") && !tokens[0].quoted {
+		return fmt.Errorf("unsupported directive %s", tokens[0].text)
+	}
+	owner := p.lastOwner
+	if !l.indented {
+		owner = p.absolute(tokens[0].text)
+		tokens = tokens[1:]
+	}
+	if owner == "" {
+		return errors.New("record without owner")
+	}
+	p.lastOwner = owner
+	ttl := p.ttl
+	for len(tokens) > 0 {
+		t := strings.ToUpper(tokens[0].text)
+		if t == "IN" || t == "CH" || t == "HS" || t == "CS" {
+			tokens = tokens[1:]
+			continue
+		}
+		if v, err := parseTTL(t); err == nil {
+			ttl = v
+			tokens = tokens[1:]
+			continue
+		}
+		break
+	}
+	if len(tokens) == 0 {
+		return errors.New("missing record type")
+	}
+	rr := zoneRR{ttl: ttl, rtype: strings.ToUpper(tokens[0].text)}
+	rdata := tokens[1:]
+	switch rr.rtype {
+	case "A", "AAAA":
+		if len(rdata) != 1 || net.ParseIP(rdata[0].text) == nil {
+			return fmt.Errorf("bad %s address", rr.rtype)
+		}
+		rr.rdata = []string{rdata[0].text}
+	case "MX":
+		if len(rdata) != 2 {
+			return errors.New("MX needs a preference and a host")
+		}
+		rr.rdata = []string{rdata[0].text, p.absolute(rdata[1].text)}
+	case "CNAME", "PTR", "NS":
+		if len(rdata) != 1 {
+			return fmt.Errorf("%s needs exactly one name", rr.rtype)
+		}
+		rr.rdata = []string{p.absolute(rdata[0].text)}
+	case "SOA":
+		if len(rdata) != 7 {
+			return errors.New("SOA needs seven fields")
+		}
+		rr.rdata = []string{p.absolute(rdata[0].text), p.absolute(rdata[1].text)}
+		for _, t := range rdata[2:] {
+			rr.rdata = append(rr.rdata, t.text)
+		}
+	case "TXT", "SPF":
+		if len(rdata) == 0 {
+			return fmt.Errorf("%s needs at least one string", rr.rtype)
+		}
+		for _, t := range rdata {
+			if len(t.text) > 255 {
+				return fmt.Errorf("%s string longer than 255 characters", rr.rtype)
+			}
+			rr.rdata = append(rr.rdata, t.text)
+		}
+	default:
+		for _, t := range rdata {
+			rr.rdata = append(rr.rdata, t.text)
+		}
+	}
+	z.add(owner, rr)
+	return nil
+}
+
+func (p *zoneParser) absolute(name string) string {
+	if name == "@" {
+		return p.origin
+	}
+	if strings.HasSuffix(name, ".") || p.origin == "" {
+		return canonicalName(name)
+	}
+	return canonicalName(name + "." + p.origin)
+}
+
+// parseTTL accepts plain seconds as well as BIND style units like 1h30m.
+func parseTTL(s string) (uint32, error) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, fmt.Errorf("bad TTL %q", s)
+	}
+	var total, current uint64
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			current = current*10 + uint64(c-'0')
+			continue
+		}
+		unit := map[rune]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}[c]
+		if unit == 0 {
+			return 0, fmt.Errorf("bad TTL %q", s)
+		}
+		total += current * unit
+		current = 0
+	}
+	total += current
+	if total > 1<<31-1 {
+		return 0, fmt.Errorf("TTL out of range %q", s)
+	}
+	return uint32(total), nil
+}
@@ -0,0 +1,119 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testZone = `
+$ORIGIN test.com.
+$TTL 1h
+@           IN SOA ns1 hostmaster ( 2024010101 ; serial
+                    3600 600 86400 300 )
+            IN NS  ns1
+            IN MX  10 mx
+            IN TXT ( "v=spf1 a mx include:_spf.test.com "
+                     "-all" )
+            IN A   192.168.1.1
+mx      300 IN A   10.5.5.1
+        300 IN AAAA 2a00:1450:4000::1
+_spf        TXT    "v=spf1 ip4:172.16.0.0/16 -all"
+quoted      TXT    "say \"hi\"" "semi\;colon" "\065BC"
+alias       CNAME  mx
+*.wild      TXT    "v=spf1 -all"
+1.1.168.192.in-addr.arpa. PTR test.com.
+`
+
+func loadTestZone(t *testing.T) *ZoneResolver {
+	z := NewZoneResolver()
+	if err := z.Load(strings.NewReader(testZone), "example.org"); err != nil {
+		t.Fatalf("loading zone should not have failed but got %q", err)
+	}
+	return z
+}
+
+func TestZoneResolverRecords(t *testing.T) {
+	z := loadTestZone(t)
+	TestTable := []struct {
+		domain string
+		txt    []string
+	}{
+		{"test.com", []string{"v=spf1 a mx include:_spf.test.com -all"}},
+		{"_SPF.test.com.", []string{"v=spf1 ip4:172.16.0.0/16 -all"}},
+		{"quoted.test.com", []string{`say "hi"semi;colonABC`}},
+		{"anything.wild.test.com", []string{"v=spf1 -all"}},
+		{"mx.test.com", []string{}},
+	}
+	for _, testCase := range TestTable {
+		txt, err := z.TextRecord(testCase.domain)
+		if err != nil {
+			t.Errorf("TXT lookup of %s should not have failed but got %q",
+				testCase.domain, err)
+		}
+		if !reflect.DeepEqual(txt, testCase.txt) {
+			t.Errorf("wrong TXT for %s wanted %q got %q",
+				testCase.domain, testCase.txt, txt)
+		}
+	}
+	ips, err := z.ARecord("alias.test.com")
+	if err != nil || len(ips) != 2 || !ips[0].Equal(net.ParseIP("10.5.5.1")) {
+		t.Errorf("CNAME was not followed got %v %q", ips, err)
+	}
+	mx, err := z.MXRecord("test.com")
+	if err != nil || len(mx) != 1 || mx[0].Host != "mx.test.com." || mx[0].Pref != 10 {
+		t.Errorf("wrong MX answer %v %q", mx, err)
+	}
+	ptr, err := z.PTRRecord("192.168.1.1")
+	if err != nil || !reflect.DeepEqual(ptr, []string{"test.com."}) {
+		t.Errorf("wrong PTR answer %v %q", ptr, err)
+	}
+	if ttl, ok := z.TTL("mx.test.com", "A"); !ok || ttl != 300 {
+		t.Errorf("wrong TTL wanted 300 got %d", ttl)
+	}
+}
+
+func TestZoneResolverNXDOMAIN(t *testing.T) {
+	z := loadTestZone(t)
+	for _, domain := range []string{"missing.test.com", "other.org", "wild.test.com.missing"} {
+		_, err := z.TextRecord(domain)
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			t.Errorf("expected NXDOMAIN for %s got %v", domain, err)
+		}
+	}
+	if _, err := z.TextRecord("wild.test.com"); err != nil {
+		t.Errorf("empty non-terminal should not be NXDOMAIN got %q", err)
+	}
+}
+
+func TestZoneResolverBadInput(t *testing.T) {
+	for _, zone := range []string{
+		"test.com. TXT ( \"open\"",
+		"test.com. TXT \"unterminated",
+		"test.com. A not-an-ip",
+		"$INCLUDE other.zone",
+	} {
+		err := NewZoneResolver().Load(strings.NewReader(zone), "")
+		if !errors.Is(err, ZoneFormatError) {
+			t.Errorf("zone %q should have failed with format error got %v", zone, err)
+		}
+	}
+}
+
+func TestZoneResolverSPF(t *testing.T) {
+	spf, err := New("test.com", loadTestZone(t))
+	if err != nil {
+		t.Fatalf("creating SPF should not have failed but got %q", err)
+	}
+	m, err := spf.Match(net.ParseIP("172.16.8.8"))
+	if err != nil {
+		t.Errorf("matching IP should not have failed but got %q", err)
+	}
+	want := []string{"include:_spf.test.com", "ip4:172.16.0.0/16"}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("wrong result wanted %v got %v", want, m)
+	}
+}